@@ -8,18 +8,37 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/tiendu/gentr/internal/cli"
 	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/control"
+	"github.com/tiendu/gentr/internal/engine"
+	"github.com/tiendu/gentr/internal/gitinfo"
+	"github.com/tiendu/gentr/internal/gopkg"
 	inputpkg "github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/listing"
+	"github.com/tiendu/gentr/internal/livereload"
+	"github.com/tiendu/gentr/internal/logging"
+	"github.com/tiendu/gentr/internal/notify"
 	"github.com/tiendu/gentr/internal/output"
+	"github.com/tiendu/gentr/internal/replay"
 	"github.com/tiendu/gentr/internal/runner"
 	"github.com/tiendu/gentr/internal/spinner"
+	"github.com/tiendu/gentr/internal/terminal"
 	"github.com/tiendu/gentr/internal/watch"
+	"github.com/tiendu/gentr/pkg/gentr"
 )
 
 func Run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
 	router := cli.NewRouter(stdout, stderr)
+	router.Register("replay", replay.Command{
+		Runner:   runner.Shell{},
+		Reporter: output.ConsoleReporter{Writer: stdout},
+		Stdout:   stdout,
+		Stderr:   stderr,
+	})
+	router.Register("ls", listing.Command{Stdout: stdout, Stderr: stderr})
 	if len(args) == 0 {
 		return cli.Help(stdout)
 	}
@@ -32,22 +51,69 @@ func Run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
 		fmt.Fprintln(stderr, err)
 		return 1
 	}
+	if opts.Config != "" {
+		file, err := config.Load(opts.Config)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		opts = file.Apply(opts)
+	}
 	fmt.Fprintln(stdout, "Starting with options:", opts)
-
-	resolver := inputpkg.FileResolver{}
-	files, err := selectInput(stdin, resolver, inputpkg.LineStdinReader{}, opts)
+	debugLog, closeDebugLog, err := openDebugLog(opts, stderr)
 	if err != nil {
-		fmt.Fprintln(stderr, err)
+		fmt.Fprintf(stderr, "[x] Error opening debug log: %v\n", err)
 		return 1
 	}
-	if len(files) == 0 {
-		fmt.Fprintln(stderr, "No files provided via STDIN or --input flag")
-		return 1
+	defer closeDebugLog()
+	debugLog.Named("app").Debugf("options: %s", terminal.StripANSI(opts.String()))
+
+	fileResolver := inputpkg.FileResolver{Include: opts.Include, Exclude: opts.Exclude, FollowSymlinks: opts.FollowSymlinks}
+	var resolver inputpkg.Resolver = fileResolver
+	var monitor *gitinfo.Monitor
+	if opts.Git {
+		repo, err := gitinfo.Open(".")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if monitor, err = gitinfo.NewMonitor(repo); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		resolver = gitinfo.Resolver{Repo: repo, Filter: fileResolver}
+	}
+	if debugLog != nil {
+		resolver = inputpkg.TracingResolver{Next: resolver, Log: debugLog.Named("input")}
+	}
+	stdinReader := inputpkg.LineStdinReader{Null: opts.NullInput}
+	var files []string
+	var updates chan gentr.PathUpdate
+	if opts.StreamStdin {
+		if !piped(stdin) {
+			fmt.Fprintln(stderr, "--stream-stdin needs paths piped through STDIN")
+			return 1
+		}
+		updates = make(chan gentr.PathUpdate)
+	} else {
+		files, err = selectInput(stdin, resolver, stdinReader, opts)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		if len(files) == 0 {
+			fmt.Fprintln(stderr, "No files provided via STDIN or --input flag")
+			return 1
+		}
 	}
-	if len(commandArgs) == 0 {
+	if len(commandArgs) == 0 && !opts.Wait && len(opts.Pipeline) == 0 {
 		fmt.Fprintln(stderr, "No command provided to execute")
 		return 1
 	}
+	if len(commandArgs) > 0 && len(opts.Pipeline) > 0 {
+		fmt.Fprintln(stderr, "A command cannot be combined with a pipeline from --config")
+		return 1
+	}
 
 	command := strings.Join(commandArgs, " ")
 	logger := output.NewSessionLogger(stdout)
@@ -58,6 +124,16 @@ func Run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
 		}
 	}
 
+	reporters := output.MultiReporter{output.ConsoleReporter{Writer: stdout}}
+	if len(opts.Webhooks) > 0 {
+		webhooks := output.NewWebhookReporter(opts.Webhooks, opts.WebhookOn, opts.WebhookTimeout, opts.WebhookRetries, stderr)
+		defer webhooks.Flush()
+		reporters = append(reporters, webhooks)
+	}
+	if opts.Notify {
+		reporters = append(reporters, notify.NewReporter(notify.DetectSender(), opts.NotifyRecovery, stderr))
+	}
+
 	activity := spinner.NewSnake(30, 5, 81, stdout)
 	activity.Start()
 	defer activity.Stop()
@@ -65,17 +141,102 @@ func Run(args []string, stdin *os.File, stdout, stderr io.Writer) int {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	watcher := watch.New(
-		opts,
-		activity,
-		runner.Shell{},
-		output.ConsoleReporter{Writer: stdout},
-		logger,
-		resolver,
-		stdout,
-	)
-	watcher.Run(ctx, files, command)
+	var instruments *gentr.Metrics
+	if opts.Listen != "" {
+		instruments = gentr.NewMetrics()
+	}
+
+	var commandRunner watch.CommandRunner = runner.Shell{}
+	if opts.Go {
+		commandRunner = gopkg.Runner{Next: commandRunner, Cache: &gopkg.Cache{}, Output: stdout}
+	}
+
+	options := []gentr.Option{
+		gentr.WithFiles(files...),
+		gentr.WithCommand(command),
+		gentr.WithSpinner(activity),
+		gentr.WithOutput(stdout),
+		gentr.WithMetrics(instruments),
+		gentr.WithEngine(func(c *engine.Config) {
+			c.Options = opts
+			c.Runner = commandRunner
+			c.Reporter = reporters
+			c.Logger = logger
+			c.Resolver = resolver
+			c.DebugLog = debugLog
+			if monitor != nil {
+				c.Git = monitor
+			}
+		}),
+	}
+	if updates != nil {
+		options = append(options, gentr.WithPathUpdates(updates))
+		go streamInput(ctx, stdin, stdinReader, updates, stderr)
+	}
+	watcher, err := gentr.New(options...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if opts.Listen != "" {
+		listener, err := control.Listen(opts.Listen)
+		if err != nil {
+			fmt.Fprintf(stderr, "[x] Error starting control API: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Control API listening on %s\n", listener.Addr())
+		handler := control.NewHandler(watcher)
+		handler.Handle("GET /metrics", instruments.Handler())
+		go func() {
+			if err := control.Serve(ctx, listener, handler); err != nil {
+				fmt.Fprintf(stderr, "[x] Control API stopped: %v\n", err)
+			}
+		}()
+	}
+
+	if opts.LiveReload != "" {
+		listener, err := control.Listen(opts.LiveReload)
+		if err != nil {
+			fmt.Fprintf(stderr, "[x] Error starting live reload server: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Live reload listening on %s, include http://%s/livereload.js in your pages\n", listener.Addr(), listener.Addr())
+		server := livereload.New()
+		events, unsubscribe := watcher.Subscribe()
+		defer unsubscribe()
+		go server.Consume(ctx, events)
+		go func() {
+			if err := control.Serve(ctx, listener, server.Handler()); err != nil {
+				fmt.Fprintf(stderr, "[x] Live reload server stopped: %v\n", err)
+			}
+		}()
+	}
+
+	started := time.Now()
+	code := watcher.Run(ctx)
+	summary := watcher.Summary()
 	fmt.Fprintln(stdout, "\nShutting down gentr...")
+	fmt.Fprintln(stdout, output.FormatSummary(runner.Summary{
+		Runs:         summary.Runs,
+		Failures:     summary.Failures,
+		LastExitCode: summary.LastExitCode,
+		CommandTime:  summary.CommandTime,
+	}, time.Since(started)))
+	return exitStatus(opts, code, summary)
+}
+
+func exitStatus(opts config.Options, code int, summary gentr.Summary) int {
+	if opts.Once || opts.ExitOnChange || opts.Wait {
+		return code
+	}
+	switch opts.ExitCode {
+	case config.ExitCodeLast:
+		return summary.LastExitCode
+	case config.ExitCodeAny:
+		if summary.Failures > 0 {
+			return 1
+		}
+	}
 	return 0
 }
 
@@ -85,9 +246,46 @@ func selectInput(
 	stdinReader inputpkg.StdinReader,
 	opts config.Options,
 ) ([]string, error) {
+	if piped(stdin) {
+		return stdinReader.ReadFiles(stdin)
+	}
+	return inputpkg.ResolveRoots(resolver, opts.InputRoots())
+}
+
+func openDebugLog(opts config.Options, stderr io.Writer) (*logging.Logger, func() error, error) {
+	if opts.DebugFile != "" {
+		file, err := os.OpenFile(opts.DebugFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		return logging.New(file, logging.LevelDebug), file.Close, nil
+	}
+	if opts.Debug {
+		return logging.New(stderr, logging.LevelDebug), func() error { return nil }, nil
+	}
+	return nil, func() error { return nil }, nil
+}
+
+func piped(stdin *os.File) bool {
 	info, err := stdin.Stat()
-	if err == nil && info.Mode()&os.ModeCharDevice == 0 {
-		return stdinReader.ReadFiles(stdin), nil
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+func streamInput(
+	ctx context.Context,
+	stdin io.Reader,
+	streamer inputpkg.StdinStreamer,
+	updates chan<- gentr.PathUpdate,
+	stderr io.Writer,
+) {
+	defer close(updates)
+	err := streamer.Stream(stdin, func(path string, remove bool) {
+		select {
+		case updates <- gentr.PathUpdate{Path: path, Remove: remove}:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "[x] Error reading STDIN: %v\n", err)
 	}
-	return resolver.Resolve(opts.Input, opts.Recursive)
 }
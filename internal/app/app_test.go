@@ -2,14 +2,18 @@ package app
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/tiendu/gentr/internal/config"
+	inputpkg "github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/pkg/gentr"
 )
 
 type stubResolver struct {
@@ -29,9 +33,9 @@ type stubStdinReader struct {
 	read  bool
 }
 
-func (r *stubStdinReader) ReadFiles(io.Reader) []string {
+func (r *stubStdinReader) ReadFiles(io.Reader) ([]string, error) {
 	r.read = true
-	return r.files
+	return r.files, nil
 }
 
 func TestSelectInputUsesPipedStdin(t *testing.T) {
@@ -70,6 +74,84 @@ func TestSelectInputReturnsResolverError(t *testing.T) {
 	}
 }
 
+func TestStreamInputForwardsUpdatesUntilEOF(t *testing.T) {
+	updates := make(chan gentr.PathUpdate)
+	var stderr bytes.Buffer
+	go streamInput(context.Background(), strings.NewReader("a.go\x00-a.go\x00b.go"), inputpkg.LineStdinReader{Null: true}, updates, &stderr)
+
+	got := make([]gentr.PathUpdate, 0)
+	for update := range updates {
+		got = append(got, update)
+	}
+	want := []gentr.PathUpdate{{Path: "a.go"}, {Path: "a.go", Remove: true}, {Path: "b.go"}}
+	if !reflect.DeepEqual(got, want) || stderr.Len() != 0 {
+		t.Fatalf("got=%+v stderr=%q", got, stderr.String())
+	}
+}
+
+func TestOpenDebugLogWritesToFileOrStderr(t *testing.T) {
+	var stderr bytes.Buffer
+	logger, closeLog, err := openDebugLog(config.New(false, false, ".", 0, false), &stderr)
+	if err != nil || logger != nil || closeLog() != nil {
+		t.Fatalf("expected no logger without --debug, got %v err=%v", logger, err)
+	}
+
+	opts := config.New(true, false, ".", 0, false)
+	logger, closeLog, err = openDebugLog(opts, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debugf("to stderr")
+	closeLog()
+	if !strings.Contains(stderr.String(), "DEBUG to stderr") {
+		t.Fatalf("unexpected stderr: %q", stderr.String())
+	}
+
+	opts.DebugFile = filepath.Join(t.TempDir(), "gentr.debug")
+	logger, closeLog, err = openDebugLog(opts, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debugf("to file")
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(opts.DebugFile); err != nil || !strings.Contains(string(data), "DEBUG to file") {
+		t.Fatalf("data=%q err=%v", data, err)
+	}
+}
+
+func TestExitStatusPolicies(t *testing.T) {
+	summary := gentr.Summary{Runs: 3, Failures: 1, LastExitCode: 0}
+	tests := []struct {
+		name   string
+		modify func(*config.Options)
+		code   int
+		want   int
+	}{
+		{"never", func(o *config.Options) {}, 0, 0},
+		{"last", func(o *config.Options) { o.ExitCode = config.ExitCodeLast }, 0, 0},
+		{"any", func(o *config.Options) { o.ExitCode = config.ExitCodeAny }, 0, 1},
+		{"once", func(o *config.Options) { o.Once = true }, 5, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := config.New(false, false, ".", 0, false)
+			test.modify(&opts)
+			if got := exitStatus(opts, test.code, summary); got != test.want {
+				t.Fatalf("expected %d, got %d", test.want, got)
+			}
+		})
+	}
+
+	last := config.New(false, false, ".", 0, false)
+	last.ExitCode = config.ExitCodeLast
+	if got := exitStatus(last, 0, gentr.Summary{Runs: 1, LastExitCode: 2}); got != 2 {
+		t.Fatalf("expected last exit code 2, got %d", got)
+	}
+}
+
 func TestRunHelpAndInvalidFlag(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	if code := Run([]string{"help"}, os.Stdin, &stdout, &stderr); code != 0 {
@@ -4,6 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tiendu/gentr/internal/buildinfo"
 	"github.com/tiendu/gentr/internal/config"
@@ -65,32 +69,322 @@ func (r *Router) Run(name string, args []string) int {
 }
 
 func Parse(args []string) (config.Options, []string, error) {
+	flags, options := NewFlagSet("gentr")
+	if err := flags.Parse(args); err != nil {
+		return config.Options{}, nil, err
+	}
+
+	opts := options()
+	modes := 0
+	for _, enabled := range []bool{opts.Once, opts.ExitOnChange, opts.Wait} {
+		if enabled {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return config.Options{}, nil, fmt.Errorf("--once, --exit-on-change and --wait are mutually exclusive")
+	}
+	switch opts.ExitCode {
+	case config.ExitCodeNever, config.ExitCodeLast, config.ExitCodeAny:
+	default:
+		return config.Options{}, nil, fmt.Errorf("invalid --exit-code %q: expected last, any or never", opts.ExitCode)
+	}
+	switch opts.WebhookOn {
+	case config.WebhookOnAll, config.WebhookOnSuccess, config.WebhookOnFailure:
+	default:
+		return config.Options{}, nil, fmt.Errorf("invalid --webhook-on %q: expected all, success or failure", opts.WebhookOn)
+	}
+	if err := validateAttributes(opts.Attributes); err != nil {
+		return config.Options{}, nil, err
+	}
+	if err := opts.Validate(); err != nil {
+		return config.Options{}, nil, err
+	}
+	if opts.StatWorkers < 1 {
+		return config.Options{}, nil, fmt.Errorf("invalid --stat-workers %d: expected at least 1", opts.StatWorkers)
+	}
+	if opts.StreamStdin && opts.Once {
+		return config.Options{}, nil, fmt.Errorf("--stream-stdin cannot be combined with --once")
+	}
+	return opts, flags.Args(), nil
+}
+
+func validateAttributes(attributes []string) error {
+	if len(attributes) == 0 {
+		return fmt.Errorf("--attributes needs at least one of %s", strings.Join(config.Attributes, ", "))
+	}
+	for _, attribute := range attributes {
+		if !slices.Contains(config.Attributes, attribute) {
+			return fmt.Errorf("invalid --attributes value %q: expected %s", attribute, strings.Join(config.Attributes, ", "))
+		}
+	}
+	return nil
+}
+
+func NewFlagSet(name string) (*flag.FlagSet, func() config.Options) {
 	var (
 		debug      bool
-		recursive  bool
-		input      string
+		debugFile  string
+		roots      rootList
 		length     int
 		logEnabled bool
+		once       bool
+		exitChange bool
+		wait       bool
+		exitCode   string
+		before     string
+		onChange   string
+		onSuccess  string
+		onFailure  string
+		configPath string
+		listen     string
+		liveReload string
+		webhooks   stringList
+		webhookOn  string
+		webhookTTL time.Duration
+		retries    int
+		notify     bool
+		recovery   bool
+		goMode     bool
+		gitMode    bool
+		attributes string
+		follow     bool
+		nullInput  bool
+		stream     bool
+		workers    int
+		poll       = time.Second
+		maxPoll    = 8 * time.Second
+		debounce   = 500 * time.Millisecond
+		rescan     = 10 * time.Second
 	)
 
-	flags := flag.NewFlagSet("gentr", flag.ContinueOnError)
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
 	flags.SetOutput(io.Discard)
 
 	flags.BoolVar(&debug, "debug", false, "Enable debug mode")
 	flags.BoolVar(&debug, "d", false, "Enable debug mode (short)")
-	flags.BoolVar(&recursive, "recursive", false, "Watch directories recursively")
-	flags.BoolVar(&recursive, "r", false, "Watch directories recursively (short)")
+	flags.StringVar(&debugFile, "debug-file", "", "Write debug diagnostics to the file instead of stderr")
+	flags.Var(rootRecursive{&roots}, "recursive", "Watch directories recursively")
+	flags.Var(rootRecursive{&roots}, "r", "Watch directories recursively (short)")
 	flags.IntVar(&length, "length", 0, "Limit output lines")
 	flags.IntVar(&length, "l", 0, "Limit output lines (short)")
-	flags.StringVar(&input, "input", ".", "Input path or glob pattern")
-	flags.StringVar(&input, "i", ".", "Input path or glob pattern (short)")
+	flags.Var(rootInput{&roots}, "input", "Input path or glob pattern (repeatable)")
+	flags.Var(rootInput{&roots}, "i", "Input path or glob pattern (short)")
 	flags.BoolVar(&logEnabled, "log", false, "Enable logging")
+	flags.Var(rootFilter{&roots, true}, "include", "Only watch files matching the pattern")
+	flags.Var(rootFilter{&roots, false}, "exclude", "Skip files matching the pattern")
+	flags.BoolVar(&once, "once", false, "Run the command once and exit with its status")
+	flags.BoolVar(&exitChange, "exit-on-change", false, "Exit with the command status after the first change")
+	flags.BoolVar(&wait, "wait", false, "Exit on the first change without running the command")
+	flags.StringVar(&exitCode, "exit-code", config.ExitCodeNever, "Exit status policy: last, any or never")
+	flags.StringVar(&before, "before", "", "Hook command run before the command")
+	flags.StringVar(&onChange, "on-change", "", "Hook command run after every command")
+	flags.StringVar(&onSuccess, "on-success", "", "Hook command run after the command succeeds")
+	flags.StringVar(&onFailure, "on-failure", "", "Hook command run after the command fails")
+	flags.StringVar(&configPath, "config", "", "Path to a JSON config file")
+	flags.StringVar(&listen, "listen", "", "Serve the control API on host:port or unix:/path")
+	flags.StringVar(&liveReload, "livereload", "", "Serve browser live reload events on host:port")
+	flags.Var(&webhooks, "webhook", "POST run results to the URL")
+	flags.StringVar(&webhookOn, "webhook-on", config.WebhookOnAll, "Send webhooks on all, success or failure")
+	flags.DurationVar(&webhookTTL, "webhook-timeout", 5*time.Second, "Webhook request timeout")
+	flags.IntVar(&retries, "webhook-retries", 2, "Webhook retries with backoff")
+	flags.BoolVar(&notify, "notify", false, "Send a desktop notification when the command fails")
+	flags.BoolVar(&recovery, "notify-recovery", false, "Also notify when the command recovers after a failure")
+	flags.BoolVar(&goMode, "go", false, "Replace ./... in the command with the Go packages affected by a change")
+	flags.BoolVar(&gitMode, "git", false, "Watch git tracked and untracked non-ignored files and react to branch switches and commits")
+	flags.BoolVar(&follow, "follow-symlinks", false, "Follow symlinked files and directories")
+	flags.BoolVar(&nullInput, "null", false, "Read NUL-separated paths from standard input")
+	flags.BoolVar(&nullInput, "0", false, "Read NUL-separated paths from standard input (short)")
+	flags.BoolVar(&stream, "stream-stdin", false, "Keep reading standard input and add or remove paths during the session")
+	flags.IntVar(&workers, "stat-workers", 8, "Files stated in parallel during a poll")
+	flags.Var(rootDuration{&roots, &poll, pollField}, "poll", "Poll interval (per root after --input)")
+	flags.Var(durationFlag{&maxPoll}, "poll-max", "Longest interval a quiet file backs off to")
+	flags.Var(rootDuration{&roots, &debounce, debounceField}, "debounce", "Wait before running after a change (per root after --input)")
+	flags.Var(durationFlag{&rescan}, "rescan", "Interval between searches for new files")
+	flags.StringVar(&attributes, "attributes", config.AttributeMtime, "Comma-separated file attributes that count as a change")
 
-	if err := flags.Parse(args); err != nil {
-		return config.Options{}, nil, err
+	return flags, func() config.Options {
+		opts := config.New(debug, roots.defaults.Recursive, ".", length, logEnabled)
+		opts.Debug = debug || debugFile != ""
+		opts.DebugFile = debugFile
+		opts.Include = roots.defaults.Include
+		opts.Exclude = roots.defaults.Exclude
+		if len(roots.roots) > 0 {
+			opts.Roots = roots.roots
+			opts.Input = roots.roots[0].Path
+			opts.Recursive = slices.ContainsFunc(roots.roots, func(root config.Root) bool { return root.Recursive })
+		}
+		opts.Once = once
+		opts.ExitOnChange = exitChange
+		opts.Wait = wait
+		opts.ExitCode = exitCode
+		opts.Before = before
+		opts.OnChange = onChange
+		opts.OnSuccess = onSuccess
+		opts.OnFailure = onFailure
+		opts.Config = configPath
+		opts.Listen = listen
+		opts.LiveReload = liveReload
+		opts.Webhooks = webhooks
+		opts.WebhookOn = webhookOn
+		opts.WebhookTimeout = webhookTTL
+		opts.WebhookRetries = retries
+		opts.Notify = notify || recovery
+		opts.NotifyRecovery = recovery
+		opts.Go = goMode
+		opts.Git = gitMode
+		opts.Attributes = splitList(attributes)
+		opts.FollowSymlinks = follow
+		opts.NullInput = nullInput
+		opts.StreamStdin = stream
+		opts.StatWorkers = workers
+		opts.PollInterval = poll
+		opts.MaxPollInterval = maxPoll
+		opts.DebounceDuration = debounce
+		opts.RescanInterval = rescan
+		maxPollSet := false
+		flags.Visit(func(f *flag.Flag) { maxPollSet = maxPollSet || f.Name == "poll-max" })
+		if !maxPollSet {
+			opts.MaxPollInterval = max(maxPoll, poll)
+		}
+		return opts
 	}
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
-	return config.New(debug, recursive, input, length, logEnabled), flags.Args(), nil
+type rootList struct {
+	defaults config.Root
+	roots    []config.Root
+}
+
+func (l *rootList) current() *config.Root {
+	if len(l.roots) == 0 {
+		return &l.defaults
+	}
+	return &l.roots[len(l.roots)-1]
+}
+
+type rootInput struct{ list *rootList }
+
+func (f rootInput) String() string {
+	if f.list == nil || len(f.list.roots) == 0 {
+		return "."
+	}
+	return f.list.roots[0].Path
+}
+
+func (f rootInput) Set(value string) error {
+	defaults := f.list.defaults
+	f.list.roots = append(f.list.roots, config.Root{
+		Path:      value,
+		Recursive: defaults.Recursive,
+		Include:   slices.Clone(defaults.Include),
+		Exclude:   slices.Clone(defaults.Exclude),
+	})
+	return nil
+}
+
+type rootRecursive struct{ list *rootList }
+
+func (f rootRecursive) IsBoolFlag() bool { return true }
+
+func (f rootRecursive) String() string {
+	return "false"
+}
+
+func (f rootRecursive) Set(value string) error {
+	recursive, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	f.list.current().Recursive = recursive
+	return nil
+}
+
+type rootFilter struct {
+	list    *rootList
+	include bool
+}
+
+func (f rootFilter) String() string {
+	return ""
+}
+
+func (f rootFilter) Set(value string) error {
+	root := f.list.current()
+	if f.include {
+		root.Include = append(root.Include, value)
+	} else {
+		root.Exclude = append(root.Exclude, value)
+	}
+	return nil
+}
+
+type durationFlag struct{ value *time.Duration }
+
+func (f durationFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return f.value.String()
+}
+
+func (f durationFlag) Set(value string) error {
+	duration, err := config.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*f.value = duration
+	return nil
+}
+
+type rootDuration struct {
+	list   *rootList
+	global *time.Duration
+	field  func(root *config.Root) *time.Duration
+}
+
+func pollField(root *config.Root) *time.Duration     { return &root.PollInterval }
+func debounceField(root *config.Root) *time.Duration { return &root.DebounceDuration }
+
+func (f rootDuration) String() string {
+	return durationFlag{f.global}.String()
+}
+
+func (f rootDuration) Set(value string) error {
+	duration, err := config.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if len(f.list.roots) == 0 {
+		*f.global = duration
+		return nil
+	}
+	if duration <= 0 {
+		return fmt.Errorf("a per-root override must be positive")
+	}
+	*f.field(f.list.current()) = duration
+	return nil
 }
 
 func Help(writer io.Writer) int {
@@ -98,20 +392,73 @@ func Help(writer io.Writer) int {
        gentr <command>
 
 Commands:
-  version      Print version
-  help         Show this message
+  version            Print version
+  help               Show this message
+  replay <logfile>   Re-run the commands recorded in a session log
+  ls [--json]        List the files a watch would pick up
 
 Watch options:
-  --debug, -d        Enable debug mode
-  --recursive, -r    Watch directories recursively
+  --debug, -d        Trace resolving, polling, debouncing, runs and reports to stderr
+  --debug-file       Write the debug trace to a file instead (implies --debug)
+  --recursive, -r    Watch directories recursively (per root)
   --length, -l       Limit output lines
   --log              Enable logging
-  --input, -i        Input path or glob pattern
+  --input, -i        Input path or glob pattern (repeatable, one watch root each)
+  --include          Only watch files matching the pattern (repeatable, per root)
+  --exclude          Skip files matching the pattern (repeatable, per root)
+  --once             Run the command once and exit with its status
+  --exit-on-change   Exit with the command status after the first change
+  --wait             Exit on the first change without running the command
+  --exit-code        Exit status on shutdown: last, any or never (default never)
+  --before           Hook command run before the command
+  --on-success       Hook command run after the command succeeds
+  --on-failure       Hook command run after the command fails
+  --on-change        Hook command run after every command
+  --config           Path to a JSON config file with a pipeline definition
+  --listen           Serve the control API on a loopback host:port or unix:/path
+  --livereload       Serve browser live reload events on a loopback host:port
+  --webhook          POST run results to the URL (repeatable)
+  --webhook-on       Send webhooks on all, success or failure (default all)
+  --webhook-timeout  Webhook request timeout (default 5s)
+  --webhook-retries  Webhook retries with backoff (default 2)
+  --notify           Send a desktop notification when the command fails
+  --notify-recovery  Also notify when the command recovers after a failure
+  --go               Replace ./... in the command with the affected Go packages
+  --git              Watch files known to git and react to branch switches and commits
+  --attributes       Attributes that count as a change: mtime, size, mode, owner, inode
+                     (comma-separated, default mtime)
+  --follow-symlinks  Follow symlinked directories and react when a symlink is retargeted
+  --stat-workers     Files stated in parallel during a poll (default 8)
+  --poll             Poll interval (default 1s, per root after --input)
+  --poll-max         Longest interval a quiet file backs off to (default 8s)
+  --debounce         Wait before running after a change (default 500ms, per root
+                     after --input)
+  --rescan           Interval between searches for new files (default 10s, at
+                     least --poll)
+  --null, -0         Read NUL-separated paths from standard input (find -print0)
+  --stream-stdin     Keep standard input open; each new path is watched and a
+                     "-path" entry stops watching it (add "./-name" for names
+                     starting with "-")
+
+Input roots:
+  Every --input starts a new watch root. --recursive, --include and --exclude
+  given before the first --input are defaults for all roots; given after an
+  --input they apply to that root only. --poll and --debounce work the same
+  way: before the first --input they set the default, after an --input they
+  override it for that root.
+
+Durations:
+  Go syntax (500ms, 1m30s), plain seconds (2, 0.5) or words (2 seconds,
+  1 minute 30 sec) are accepted.
 
 Examples:
   gentr --input 'logs/*.log' 'echo changed /_'
   find testdir -type f | gentr cat /_
+  find . -name '*.go' -print0 | gentr -0 go build ./...
   gentr --input . --recursive go test ./...
+  gentr -i cmd -r -i internal -r --exclude '*_test.go' -i config.yaml make
+  gentr --poll 250ms --debounce 1s -i src -r -i docs -r --poll 5s make
+  gentr replay --compare 2026-06-16T10-00-00.log
 `)
 	return 0
 }
@@ -2,8 +2,12 @@ package cli
 
 import (
 	"bytes"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
 )
 
 type recordingCommand struct {
@@ -56,6 +60,49 @@ func TestParseLongAndShortFlags(t *testing.T) {
 	}
 }
 
+func TestParseRepeatableFilters(t *testing.T) {
+	opts, _, err := Parse([]string{"--include", "*.go", "--include", "*.mod", "--exclude", "*_test.go", "true"})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if strings.Join(opts.Include, ",") != "*.go,*.mod" || strings.Join(opts.Exclude, ",") != "*_test.go" {
+		t.Fatalf("unexpected filters: %+v", opts)
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	opts, _, err := Parse([]string{"make"})
+	if err != nil || strings.Join(opts.Attributes, ",") != "mtime" {
+		t.Fatalf("unexpected default attributes: %v err=%v", opts.Attributes, err)
+	}
+	opts, _, err = Parse([]string{"--attributes", "mtime, mode,owner", "make"})
+	if err != nil || strings.Join(opts.Attributes, ",") != "mtime,mode,owner" {
+		t.Fatalf("unexpected attributes: %v err=%v", opts.Attributes, err)
+	}
+	if _, _, err := Parse([]string{"--attributes", "color", "make"}); err == nil {
+		t.Fatal("expected an error for an unknown attribute")
+	}
+	if _, _, err := Parse([]string{"--attributes", "", "make"}); err == nil {
+		t.Fatal("expected an error for an empty attribute list")
+	}
+}
+
+func TestParseExitModes(t *testing.T) {
+	opts, _, err := Parse([]string{"--exit-on-change", "make"})
+	if err != nil || !opts.ExitOnChange || opts.Once || opts.Wait {
+		t.Fatalf("opts=%+v err=%v", opts, err)
+	}
+	if _, _, err := Parse([]string{"--once", "--wait"}); err == nil {
+		t.Fatal("expected conflicting exit modes to return an error")
+	}
+	if opts, _, err := Parse([]string{"--exit-code", "any", "make"}); err != nil || opts.ExitCode != "any" {
+		t.Fatalf("opts=%+v err=%v", opts, err)
+	}
+	if _, _, err := Parse([]string{"--exit-code", "sometimes"}); err == nil {
+		t.Fatal("expected invalid exit code policy to return an error")
+	}
+}
+
 func TestParseRejectsUnknownFlag(t *testing.T) {
 	if _, _, err := Parse([]string{"--nope"}); err == nil {
 		t.Fatal("expected unknown flag to return an error")
@@ -99,3 +146,107 @@ func TestHelpAndVersionWriteOutput(t *testing.T) {
 type ioDiscard struct{}
 
 func (ioDiscard) Write(data []byte) (int, error) { return len(data), nil }
+
+func TestParseRepeatableInputRoots(t *testing.T) {
+	opts, _, err := Parse([]string{
+		"--exclude", "*.tmp",
+		"-i", "cmd", "-r", "--include", "*.go",
+		"--input", "internal", "--recursive", "--exclude", "*_test.go",
+		"-i", "config.yaml",
+		"make",
+	})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	want := []config.Root{
+		{Path: "cmd", Recursive: true, Include: []string{"*.go"}, Exclude: []string{"*.tmp"}},
+		{Path: "internal", Recursive: true, Exclude: []string{"*.tmp", "*_test.go"}},
+		{Path: "config.yaml", Exclude: []string{"*.tmp"}},
+	}
+	if !reflect.DeepEqual(opts.Roots, want) {
+		t.Fatalf("roots=%+v", opts.Roots)
+	}
+	if opts.Input != "cmd" || !opts.Recursive || strings.Join(opts.Exclude, ",") != "*.tmp" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+}
+
+func TestParseStdinModes(t *testing.T) {
+	opts, _, err := Parse([]string{"-0", "--stream-stdin", "make"})
+	if err != nil || !opts.NullInput || !opts.StreamStdin {
+		t.Fatalf("unexpected options: %+v err=%v", opts, err)
+	}
+	if _, _, err := Parse([]string{"--stream-stdin", "--once", "make"}); err == nil {
+		t.Fatal("expected --stream-stdin and --once to be rejected together")
+	}
+}
+
+func TestParseDebugFileImpliesDebug(t *testing.T) {
+	opts, _, err := Parse([]string{"--debug-file", "gentr.debug", "make"})
+	if err != nil || !opts.Debug || opts.DebugFile != "gentr.debug" {
+		t.Fatalf("unexpected options: %+v err=%v", opts, err)
+	}
+}
+
+func TestParseStatWorkers(t *testing.T) {
+	opts, _, err := Parse([]string{"make"})
+	if err != nil || opts.StatWorkers != 8 {
+		t.Fatalf("unexpected default workers: %d err=%v", opts.StatWorkers, err)
+	}
+	opts, _, err = Parse([]string{"--stat-workers", "32", "make"})
+	if err != nil || opts.StatWorkers != 32 {
+		t.Fatalf("unexpected workers: %d err=%v", opts.StatWorkers, err)
+	}
+	if _, _, err := Parse([]string{"--stat-workers", "0", "make"}); err == nil {
+		t.Fatal("expected an error for zero workers")
+	}
+}
+
+func TestParseTimingFlags(t *testing.T) {
+	opts, _, err := Parse([]string{"--poll", "250ms", "--debounce", "2 seconds", "--rescan", "1m", "make"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.PollInterval != 250*time.Millisecond || opts.DebounceDuration != 2*time.Second || opts.RescanInterval != time.Minute {
+		t.Fatalf("unexpected timing: %+v", opts)
+	}
+	if opts.MaxPollInterval != 8*time.Second {
+		t.Fatalf("unexpected poll ceiling: %s", opts.MaxPollInterval)
+	}
+
+	opts, _, err = Parse([]string{"--poll", "20", "--rescan", "30s", "make"})
+	if err != nil || opts.PollInterval != 20*time.Second || opts.MaxPollInterval != 20*time.Second {
+		t.Fatalf("slow poll: poll=%s ceiling=%s err=%v", opts.PollInterval, opts.MaxPollInterval, err)
+	}
+
+	for _, args := range [][]string{
+		{"--poll", "0", "make"},
+		{"--debounce", "-1s", "make"},
+		{"--poll", "2s", "--rescan", "1s", "make"},
+		{"--poll", "2s", "--poll-max", "1s", "make"},
+		{"--poll", "soon", "make"},
+		{"-i", "src", "--poll", "20s", "make"},
+		{"-i", "src", "--debounce", "0", "make"},
+	} {
+		if _, _, err := Parse(args); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestParsePerRootTimingOverrides(t *testing.T) {
+	opts, _, err := Parse([]string{"--debounce", "1s", "-i", "src", "--poll", "250ms", "-i", "docs", "--debounce", "3s", "make"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.PollInterval != time.Second || opts.DebounceDuration != time.Second {
+		t.Fatalf("unexpected defaults: poll=%s debounce=%s", opts.PollInterval, opts.DebounceDuration)
+	}
+	want := []config.Root{
+		{Path: "src", PollInterval: 250 * time.Millisecond},
+		{Path: "docs", DebounceDuration: 3 * time.Second},
+	}
+	if !reflect.DeepEqual(opts.Roots, want) {
+		t.Fatalf("expected %+v, got %+v", want, opts.Roots)
+	}
+}
@@ -0,0 +1,186 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Real struct{}
+
+func (Real) Now() time.Time                  { return time.Now() }
+func (Real) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (Real) NewTicker(d time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(d)}
+}
+
+func (Real) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+type realTicker struct{ ticker *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+type realTimer struct{ timer *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.timer.C }
+func (t realTimer) Stop() bool          { return t.timer.Stop() }
+
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{}
+}
+
+type fakeWaiter struct {
+	clock   *Fake
+	when    time.Time
+	period  time.Duration
+	channel chan time.Time
+	stopped bool
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return fakeTicker{waiter: f.addWaiter(d, d)}
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.addWaiter(d, 0)
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		next := f.nextWaiter(target)
+		if next == nil {
+			break
+		}
+		f.now = next.when
+		next.fire()
+	}
+	f.now = target
+	f.compact()
+}
+
+func (f *Fake) BlockUntil(waiters int) {
+	for {
+		f.mutex.Lock()
+		active, changed := len(f.waiters), f.changed
+		f.mutex.Unlock()
+		if active >= waiters {
+			return
+		}
+		<-changed
+	}
+}
+
+func (f *Fake) addWaiter(d, period time.Duration) *fakeWaiter {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	waiter := &fakeWaiter{clock: f, when: f.now.Add(d), period: period, channel: make(chan time.Time, 1)}
+	if d <= 0 {
+		waiter.fire()
+		return waiter
+	}
+	f.waiters = append(f.waiters, waiter)
+	f.notify()
+	return waiter
+}
+
+func (f *Fake) nextWaiter(target time.Time) *fakeWaiter {
+	var next *fakeWaiter
+	for _, waiter := range f.waiters {
+		if waiter.stopped || waiter.when.After(target) {
+			continue
+		}
+		if next == nil || waiter.when.Before(next.when) {
+			next = waiter
+		}
+	}
+	return next
+}
+
+func (f *Fake) compact() {
+	active := f.waiters[:0]
+	for _, waiter := range f.waiters {
+		if !waiter.stopped {
+			active = append(active, waiter)
+		}
+	}
+	f.waiters = active
+}
+
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (w *fakeWaiter) fire() {
+	select {
+	case w.channel <- w.when:
+	default:
+	}
+	if w.period > 0 {
+		w.when = w.when.Add(w.period)
+		return
+	}
+	w.stopped = true
+}
+
+func (w *fakeWaiter) C() <-chan time.Time { return w.channel }
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mutex.Lock()
+	defer w.clock.mutex.Unlock()
+	active := !w.stopped
+	w.stopped = true
+	w.clock.compact()
+	w.clock.notify()
+	return active
+}
+
+type fakeTicker struct{ waiter *fakeWaiter }
+
+func (t fakeTicker) C() <-chan time.Time { return t.waiter.C() }
+func (t fakeTicker) Stop()               { t.waiter.Stop() }
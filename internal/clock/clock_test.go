@@ -0,0 +1,75 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeTickerFiresOnAdvance(t *testing.T) {
+	clock := NewFake(epoch)
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before the clock advanced")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if tick := <-ticker.C(); !tick.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("unexpected tick time: %v", tick)
+	}
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before its next interval")
+	default:
+	}
+	clock.Advance(500 * time.Millisecond)
+	<-ticker.C()
+	if elapsed := clock.Since(epoch); elapsed != 2*time.Second {
+		t.Fatalf("unexpected elapsed time: %v", elapsed)
+	}
+}
+
+func TestFakeTimerFiresOnceAndStops(t *testing.T) {
+	clock := NewFake(epoch)
+	timer := clock.NewTimer(time.Minute)
+	clock.Advance(time.Hour)
+	<-timer.C()
+	if timer.Stop() {
+		t.Fatal("fired timer should report it was not active")
+	}
+
+	immediate := clock.NewTimer(0)
+	<-immediate.C()
+
+	stopped := clock.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Fatal("pending timer should report it was active")
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeBlockUntilWaitsForWaiters(t *testing.T) {
+	clock := NewFake(epoch)
+	fired := make(chan time.Time)
+	go func() {
+		timer := clock.NewTimer(time.Second)
+		fired <- <-timer.C()
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if got := <-fired; !got.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("unexpected fire time: %v", got)
+	}
+}
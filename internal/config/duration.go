@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var durationUnits = map[string]time.Duration{
+	"ms":           time.Millisecond,
+	"msec":         time.Millisecond,
+	"msecs":        time.Millisecond,
+	"millisecond":  time.Millisecond,
+	"milliseconds": time.Millisecond,
+	"s":            time.Second,
+	"sec":          time.Second,
+	"secs":         time.Second,
+	"second":       time.Second,
+	"seconds":      time.Second,
+	"m":            time.Minute,
+	"min":          time.Minute,
+	"mins":         time.Minute,
+	"minute":       time.Minute,
+	"minutes":      time.Minute,
+	"h":            time.Hour,
+	"hr":           time.Hour,
+	"hrs":          time.Hour,
+	"hour":         time.Hour,
+	"hours":        time.Hour,
+}
+
+func ParseDuration(value string) (time.Duration, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	if duration, err := time.ParseDuration(text); err == nil {
+		return duration, nil
+	}
+	invalid := fmt.Errorf("invalid duration %q: expected a value such as 500ms, 2s, 1.5m, 1m30s or \"2 minutes\"", value)
+	if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+		duration, ok := scale(seconds, time.Second)
+		if !ok {
+			return 0, invalid
+		}
+		return duration, nil
+	}
+
+	text = strings.ReplaceAll(text, " and ", " ")
+	text = strings.ReplaceAll(text, ",", " ")
+	var total time.Duration
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		end := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+		if end <= 0 {
+			return 0, invalid
+		}
+		amount, err := strconv.ParseFloat(text[:end], 64)
+		if err != nil {
+			return 0, invalid
+		}
+		text = strings.TrimLeft(text[end:], " ")
+		end = strings.IndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) })
+		if end < 0 {
+			end = len(text)
+		}
+		unit, ok := durationUnits[text[:end]]
+		if !ok {
+			return 0, invalid
+		}
+		duration, ok := scale(amount, unit)
+		if !ok || total > math.MaxInt64-duration {
+			return 0, invalid
+		}
+		total += duration
+		text = text[end:]
+	}
+	if total == 0 && !strings.ContainsAny(value, "0123456789") {
+		return 0, invalid
+	}
+	return total, nil
+}
+
+func scale(amount float64, unit time.Duration) (time.Duration, bool) {
+	value := amount * float64(unit)
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.Abs(value) >= math.MaxInt64 {
+		return 0, false
+	}
+	return time.Duration(value), true
+}
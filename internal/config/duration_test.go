@@ -0,0 +1,36 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDurationAcceptsHumanSyntax(t *testing.T) {
+	tests := map[string]time.Duration{
+		"500ms":                500 * time.Millisecond,
+		"1m30s":                90 * time.Second,
+		"2":                    2 * time.Second,
+		"0.25":                 250 * time.Millisecond,
+		"1.5m":                 90 * time.Second,
+		"2 seconds":            2 * time.Second,
+		"1 minute 30 sec":      90 * time.Second,
+		"1 hour and 5 minutes": 65 * time.Minute,
+		" 750 Milliseconds ":   750 * time.Millisecond,
+		"1h, 2m":               62 * time.Minute,
+		"0":                    0,
+	}
+	for value, want := range tests {
+		got, err := ParseDuration(value)
+		if err != nil || got != want {
+			t.Fatalf("%q: got=%s err=%v, want %s", value, got, err, want)
+		}
+	}
+}
+
+func TestParseDurationRejectsUnknownUnits(t *testing.T) {
+	for _, value := range []string{"", "soon", "5 fortnights", "ms", "1.2.3s", "inf", "-Inf", "nan", "1e300", "1e300 seconds", "2000000 hours 2000000 hours"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Fatalf("%q: expected error", value)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type File struct {
+	Pipeline []Step `json:"pipeline"`
+}
+
+type Step struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Needs   []string `json:"needs,omitempty"`
+	When    []string `json:"when,omitempty"`
+}
+
+func Load(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return File{}, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	if err := validatePipeline(file.Pipeline); err != nil {
+		return File{}, fmt.Errorf("invalid pipeline in %s: %w", path, err)
+	}
+	return file, nil
+}
+
+func (f File) Apply(opts Options) Options {
+	opts.Pipeline = f.Pipeline
+	return opts
+}
+
+func validatePipeline(steps []Step) error {
+	names := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		if step.Name == "" {
+			return fmt.Errorf("step without a name")
+		}
+		if step.Command == "" {
+			return fmt.Errorf("step %q has no command", step.Name)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step %q", step.Name)
+		}
+		names[step.Name] = true
+	}
+	for _, step := range steps {
+		for _, need := range step.Needs {
+			if !names[need] {
+				return fmt.Errorf("step %q needs unknown step %q", step.Name, need)
+			}
+		}
+	}
+
+	if _, err := Waves(steps); err != nil {
+		return err
+	}
+	return nil
+}
+
+func Waves(steps []Step) ([][]Step, error) {
+	done := make(map[string]bool, len(steps))
+	remaining := append([]Step(nil), steps...)
+	waves := make([][]Step, 0)
+	for len(remaining) > 0 {
+		wave := make([]Step, 0)
+		pending := make([]Step, 0)
+		for _, step := range remaining {
+			if needsSatisfied(step, done) {
+				wave = append(wave, step)
+			} else {
+				pending = append(pending, step)
+			}
+		}
+		if len(wave) == 0 {
+			return nil, fmt.Errorf("dependency cycle between steps %s", stepNames(pending))
+		}
+		for _, step := range wave {
+			done[step.Name] = true
+		}
+		waves = append(waves, wave)
+		remaining = pending
+	}
+	return waves, nil
+}
+
+func needsSatisfied(step Step, done map[string]bool) bool {
+	for _, need := range step.Needs {
+		if !done[need] {
+			return false
+		}
+	}
+	return true
+}
+
+func stepNames(steps []Step) string {
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadAppliesPipeline(t *testing.T) {
+	path := writeConfig(t, `{"pipeline": [
+		{"name": "build", "command": "go build ./..."},
+		{"name": "test", "command": "go test ./...", "needs": ["build"], "when": ["*.go"]}
+	]}`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := file.Apply(New(false, false, ".", 0, false))
+	if len(opts.Pipeline) != 2 || opts.Pipeline[1].Needs[0] != "build" || opts.Pipeline[1].When[0] != "*.go" {
+		t.Fatalf("unexpected pipeline: %+v", opts.Pipeline)
+	}
+}
+
+func TestLoadRejectsInvalidPipelines(t *testing.T) {
+	tests := map[string]string{
+		"unknown dependency": `{"pipeline": [{"name": "a", "command": "true", "needs": ["b"]}]}`,
+		"duplicate":          `{"pipeline": [{"name": "a", "command": "true"}, {"name": "a", "command": "true"}]}`,
+		"cycle":              `{"pipeline": [{"name": "a", "command": "true", "needs": ["b"]}, {"name": "b", "command": "true", "needs": ["a"]}]}`,
+		"missing command":    `{"pipeline": [{"name": "a"}]}`,
+		"syntax":             `{"pipeline": [`,
+	}
+	for name, content := range tests {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWavesGroupIndependentSteps(t *testing.T) {
+	waves, err := Waves([]Step{
+		{Name: "build"},
+		{Name: "test", Needs: []string{"build"}},
+		{Name: "lint", Needs: []string{"build"}},
+	})
+	if err != nil || len(waves) != 2 || len(waves[1]) != 2 {
+		t.Fatalf("waves=%+v err=%v", waves, err)
+	}
+	if _, err := Waves([]Step{{Name: "a", Needs: []string{"a"}}}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gentr.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
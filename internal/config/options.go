@@ -2,20 +2,105 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tiendu/gentr/internal/terminal"
 )
 
+const (
+	ExitCodeNever = "never"
+	ExitCodeLast  = "last"
+	ExitCodeAny   = "any"
+)
+
+const (
+	WebhookOnAll     = "all"
+	WebhookOnSuccess = "success"
+	WebhookOnFailure = "failure"
+)
+
+const (
+	AttributeMtime = "mtime"
+	AttributeSize  = "size"
+	AttributeMode  = "mode"
+	AttributeOwner = "owner"
+	AttributeInode = "inode"
+
+	AttributeTarget = "target"
+)
+
+var Attributes = []string{AttributeMtime, AttributeSize, AttributeMode, AttributeOwner, AttributeInode}
+
 type Options struct {
 	Debug            bool
+	DebugFile        string
 	Recursive        bool
 	Input            string
 	Length           int
 	Log              bool
+	Include          []string
+	Exclude          []string
+	Once             bool
+	ExitOnChange     bool
+	Wait             bool
+	ExitCode         string
+	Before           string
+	OnChange         string
+	OnSuccess        string
+	OnFailure        string
+	Config           string
+	Pipeline         []Step
+	Listen           string
+	LiveReload       string
+	Webhooks         []string
+	WebhookOn        string
+	WebhookTimeout   time.Duration
+	WebhookRetries   int
+	Notify           bool
+	NotifyRecovery   bool
+	Go               bool
+	Git              bool
 	PollInterval     time.Duration
+	MaxPollInterval  time.Duration
+	StatWorkers      int
 	DebounceDuration time.Duration
 	RescanInterval   time.Duration
+	Attributes       []string
+	FollowSymlinks   bool
+	Roots            []Root
+	NullInput        bool
+	StreamStdin      bool
+}
+
+type Root struct {
+	Path             string
+	Recursive        bool
+	Include          []string
+	Exclude          []string
+	PollInterval     time.Duration
+	DebounceDuration time.Duration
+}
+
+func (r Root) String() string {
+	details := make([]string, 0)
+	if r.Recursive {
+		details = append(details, "recursive")
+	}
+	if r.PollInterval > 0 {
+		details = append(details, "poll "+r.PollInterval.String())
+	}
+	if r.DebounceDuration > 0 {
+		details = append(details, "debounce "+r.DebounceDuration.String())
+	}
+	if len(details) == 0 {
+		return r.Path
+	}
+	return r.Path + " (" + strings.Join(details, ", ") + ")"
+}
+
+func (r Root) overridesTiming() bool {
+	return r.PollInterval > 0 || r.DebounceDuration > 0
 }
 
 func New(debug, recursive bool, input string, length int, logEnabled bool) Options {
@@ -25,10 +110,81 @@ func New(debug, recursive bool, input string, length int, logEnabled bool) Optio
 		Input:            input,
 		Length:           length,
 		Log:              logEnabled,
+		ExitCode:         ExitCodeNever,
+		WebhookOn:        WebhookOnAll,
+		WebhookTimeout:   5 * time.Second,
+		WebhookRetries:   2,
 		PollInterval:     time.Second,
+		MaxPollInterval:  8 * time.Second,
+		StatWorkers:      8,
 		DebounceDuration: 500 * time.Millisecond,
 		RescanInterval:   10 * time.Second,
+		Attributes:       []string{AttributeMtime},
+	}
+}
+
+func (o Options) InputRoots() []Root {
+	if len(o.Roots) > 0 {
+		return o.Roots
+	}
+	return []Root{{Path: o.Input, Recursive: o.Recursive, Include: o.Include, Exclude: o.Exclude}}
+}
+
+func (o Options) PollFor(root Root) time.Duration {
+	if root.PollInterval > 0 {
+		return root.PollInterval
+	}
+	return o.PollInterval
+}
+
+func (o Options) DebounceFor(root Root) time.Duration {
+	if root.DebounceDuration > 0 {
+		return root.DebounceDuration
+	}
+	return o.DebounceDuration
+}
+
+func (o Options) PollTick() time.Duration {
+	tick := o.PollInterval
+	for _, root := range o.Roots {
+		if root.PollInterval > 0 && root.PollInterval < tick {
+			tick = root.PollInterval
+		}
+	}
+	return tick
+}
+
+func (o Options) OverridesTiming() bool {
+	for _, root := range o.Roots {
+		if root.overridesTiming() {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) Validate() error {
+	if o.PollInterval <= 0 {
+		return fmt.Errorf("invalid --poll %s: expected a positive interval", o.PollInterval)
+	}
+	if o.DebounceDuration < 0 {
+		return fmt.Errorf("invalid --debounce %s: expected zero or a positive duration", o.DebounceDuration)
+	}
+	if o.MaxPollInterval < o.PollInterval {
+		return fmt.Errorf("--poll-max %s is shorter than --poll %s", o.MaxPollInterval, o.PollInterval)
 	}
+	if o.RescanInterval < o.PollInterval {
+		return fmt.Errorf("--rescan %s is shorter than --poll %s", o.RescanInterval, o.PollInterval)
+	}
+	for _, root := range o.Roots {
+		if root.PollInterval < 0 || root.DebounceDuration < 0 {
+			return fmt.Errorf("invalid timing override for --input %s: expected positive durations", root.Path)
+		}
+		if poll := o.PollFor(root); o.RescanInterval < poll {
+			return fmt.Errorf("--rescan %s is shorter than --poll %s of --input %s", o.RescanInterval, poll, root.Path)
+		}
+	}
+	return nil
 }
 
 func (o Options) String() string {
@@ -44,13 +200,31 @@ func (o Options) String() string {
 		}
 		return terminal.Highlight("none", "white", "red")
 	}
+	formatDuration := func(value time.Duration) string {
+		return terminal.Highlight(value.String(), "white", "green")
+	}
 
 	return fmt.Sprintf(
-		"--debug %s; --recursive %s; --length %s; --log %s; --input %s",
+		"--debug %s; --recursive %s; --length %s; --log %s; --input %s; --poll %s; --poll-max %s; --debounce %s; --rescan %s",
 		formatBool(o.Debug),
 		formatBool(o.Recursive),
 		formatInt(o.Length),
 		formatBool(o.Log),
-		terminal.Bold(terminal.Color(o.Input, "cyan")),
+		terminal.Bold(terminal.Color(o.inputs(), "cyan")),
+		formatDuration(o.PollInterval),
+		formatDuration(o.MaxPollInterval),
+		formatDuration(o.DebounceDuration),
+		formatDuration(o.RescanInterval),
 	)
 }
+
+func (o Options) inputs() string {
+	if len(o.Roots) == 0 || len(o.Roots) == 1 && !o.Roots[0].overridesTiming() {
+		return o.Input
+	}
+	roots := make([]string, 0, len(o.Roots))
+	for _, root := range o.Roots {
+		roots = append(roots, root.String())
+	}
+	return strings.Join(roots, ", ")
+}
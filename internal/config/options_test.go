@@ -24,9 +24,65 @@ func TestNewUsesOperationalDefaults(t *testing.T) {
 
 func TestOptionsString(t *testing.T) {
 	text := terminal.StripANSI(New(false, true, ".", 0, false).String())
-	for _, expected := range []string{"--debug false", "--recursive true", "--length none", "--log false", "--input ."} {
+	for _, expected := range []string{"--debug false", "--recursive true", "--length none", "--log false", "--input .", "--poll 1s", "--poll-max 8s", "--debounce 500ms", "--rescan 10s"} {
 		if !strings.Contains(text, expected) {
 			t.Fatalf("expected %q in %q", expected, text)
 		}
 	}
 }
+
+func TestInputRootsFallsBackToInput(t *testing.T) {
+	opts := New(false, true, "./src", 0, false)
+	opts.Include = []string{"*.go"}
+	roots := opts.InputRoots()
+	if len(roots) != 1 || roots[0].Path != "./src" || !roots[0].Recursive || strings.Join(roots[0].Include, ",") != "*.go" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+
+	opts.Roots = []Root{{Path: "cmd", Recursive: true}, {Path: "config.yaml"}}
+	if roots := opts.InputRoots(); len(roots) != 2 || roots[1].Path != "config.yaml" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+	text := terminal.StripANSI(opts.String())
+	if !strings.Contains(text, "--input cmd (recursive), config.yaml") {
+		t.Fatalf("expected every root in %q", text)
+	}
+}
+
+func TestRootTimingOverrides(t *testing.T) {
+	opts := New(false, false, ".", 0, false)
+	opts.Roots = []Root{{Path: "src", PollInterval: 250 * time.Millisecond}, {Path: "docs", Recursive: true, DebounceDuration: 2 * time.Second}}
+
+	if opts.PollTick() != 250*time.Millisecond || opts.PollFor(opts.Roots[1]) != time.Second {
+		t.Fatalf("unexpected poll intervals: tick=%s docs=%s", opts.PollTick(), opts.PollFor(opts.Roots[1]))
+	}
+	if opts.DebounceFor(opts.Roots[0]) != 500*time.Millisecond || opts.DebounceFor(opts.Roots[1]) != 2*time.Second {
+		t.Fatalf("unexpected debounce: src=%s docs=%s", opts.DebounceFor(opts.Roots[0]), opts.DebounceFor(opts.Roots[1]))
+	}
+	if !opts.OverridesTiming() {
+		t.Fatal("expected timing overrides")
+	}
+	text := terminal.StripANSI(opts.String())
+	if !strings.Contains(text, "--input src (poll 250ms), docs (recursive, debounce 2s)") {
+		t.Fatalf("expected root overrides in %q", text)
+	}
+}
+
+func TestValidateChecksTiming(t *testing.T) {
+	if err := New(false, false, ".", 0, false).Validate(); err != nil {
+		t.Fatalf("defaults should be valid: %v", err)
+	}
+	for name, change := range map[string]func(*Options){
+		"zero poll":         func(o *Options) { o.PollInterval = 0 },
+		"negative debounce": func(o *Options) { o.DebounceDuration = -time.Second },
+		"short ceiling":     func(o *Options) { o.MaxPollInterval = o.PollInterval / 2 },
+		"short rescan":      func(o *Options) { o.RescanInterval = o.PollInterval / 2 },
+		"slow root":         func(o *Options) { o.Roots = []Root{{Path: "docs", PollInterval: time.Minute}} },
+	} {
+		opts := New(false, false, ".", 0, false)
+		change(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
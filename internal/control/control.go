@@ -0,0 +1,203 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tiendu/gentr/pkg/gentr"
+)
+
+type Controller interface {
+	Status() gentr.Status
+	Files() []string
+	Events() []gentr.Event
+	LastResult() (gentr.Result, bool)
+	Trigger()
+	Pause()
+	Resume()
+	Reload() error
+}
+
+type Result struct {
+	Command    string `json:"command"`
+	File       string `json:"file"`
+	ExitCode   int    `json:"exit_code"`
+	DurationMS int64  `json:"duration_ms"`
+	Output     string `json:"output"`
+}
+
+type Handler struct {
+	mux *http.ServeMux
+}
+
+func (h *Handler) Handle(pattern string, handler http.Handler) {
+	h.mux.Handle(pattern, handler)
+}
+
+func (h *Handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if !unixSocket(request) && !loopbackHost(request.Host) {
+		writeJSON(writer, http.StatusForbidden, message("the control API only answers requests for localhost"))
+		return
+	}
+	if request.Method == http.MethodPost && !jsonContent(request.Header.Get("Content-Type")) {
+		writeJSON(writer, http.StatusUnsupportedMediaType, message("POST requests need Content-Type: application/json"))
+		return
+	}
+	h.mux.ServeHTTP(writer, request)
+}
+
+func unixSocket(request *http.Request) bool {
+	address, ok := request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && address.Network() == "unix"
+}
+
+func loopbackHost(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	address := net.ParseIP(host)
+	return address != nil && address.IsLoopback()
+}
+
+func jsonContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+func NewHandler(controller Controller) *Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(writer http.ResponseWriter, _ *http.Request) {
+		writeJSON(writer, http.StatusOK, controller.Status())
+	})
+	mux.HandleFunc("GET /files", func(writer http.ResponseWriter, _ *http.Request) {
+		writeJSON(writer, http.StatusOK, controller.Files())
+	})
+	mux.HandleFunc("GET /events", func(writer http.ResponseWriter, _ *http.Request) {
+		writeJSON(writer, http.StatusOK, controller.Events())
+	})
+	mux.HandleFunc("GET /result", func(writer http.ResponseWriter, _ *http.Request) {
+		result, ok := controller.LastResult()
+		if !ok {
+			writeJSON(writer, http.StatusNotFound, message("no command has run yet"))
+			return
+		}
+		writeJSON(writer, http.StatusOK, Result{
+			Command:    result.Command,
+			File:       result.File,
+			ExitCode:   result.ExitCode,
+			DurationMS: result.Duration.Milliseconds(),
+			Output:     result.RawOutput,
+		})
+	})
+	mux.HandleFunc("POST /run", func(writer http.ResponseWriter, _ *http.Request) {
+		controller.Trigger()
+		writeJSON(writer, http.StatusAccepted, message("run triggered"))
+	})
+	mux.HandleFunc("POST /pause", func(writer http.ResponseWriter, _ *http.Request) {
+		controller.Pause()
+		writeJSON(writer, http.StatusOK, message("paused"))
+	})
+	mux.HandleFunc("POST /resume", func(writer http.ResponseWriter, _ *http.Request) {
+		controller.Resume()
+		writeJSON(writer, http.StatusOK, message("resumed"))
+	})
+	mux.HandleFunc("POST /reload", func(writer http.ResponseWriter, _ *http.Request) {
+		if err := controller.Reload(); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, gentr.ErrBusy) {
+				status = http.StatusConflict
+			}
+			writeJSON(writer, status, message(err.Error()))
+			return
+		}
+		writeJSON(writer, http.StatusOK, message("reloaded"))
+	})
+	return &Handler{mux: mux}
+}
+
+func Listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if err := requireLoopback(host); err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", net.JoinHostPort(host, port))
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("refusing to replace %s: it exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+func requireLoopback(host string) error {
+	addresses := []net.IP{net.ParseIP(host)}
+	if addresses[0] == nil {
+		var err error
+		if addresses, err = net.LookupIP(host); err != nil {
+			return err
+		}
+	}
+	for _, address := range addresses {
+		if !address.IsLoopback() {
+			return fmt.Errorf("refusing to listen on %s: only loopback addresses and unix: sockets are allowed", host)
+		}
+	}
+	return nil
+}
+
+func Serve(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func message(text string) map[string]string {
+	return map[string]string{"message": text}
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(value)
+}
@@ -0,0 +1,271 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/pkg/gentr"
+)
+
+type fakeController struct {
+	triggered, paused, resumed int
+	reloadErr                  error
+	result                     *gentr.Result
+}
+
+func (c *fakeController) Status() gentr.Status {
+	return gentr.Status{State: "watching", Files: 2, Runs: 1}
+}
+func (c *fakeController) Files() []string { return []string{"a.go", "b.go"} }
+func (c *fakeController) Events() []gentr.Event {
+	return []gentr.Event{{Kind: gentr.EventChanged, Path: "a.go"}}
+}
+func (c *fakeController) LastResult() (gentr.Result, bool) {
+	if c.result == nil {
+		return gentr.Result{}, false
+	}
+	return *c.result, true
+}
+func (c *fakeController) Trigger()      { c.triggered++ }
+func (c *fakeController) Pause()        { c.paused++ }
+func (c *fakeController) Resume()       { c.resumed++ }
+func (c *fakeController) Reload() error { return c.reloadErr }
+
+func TestHandlerServesState(t *testing.T) {
+	controller := &fakeController{}
+	server := httptest.NewServer(NewHandler(controller))
+	defer server.Close()
+
+	var status gentr.Status
+	getJSON(t, server.URL+"/status", http.StatusOK, &status)
+	if status.Files != 2 || status.State != "watching" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	var files []string
+	getJSON(t, server.URL+"/files", http.StatusOK, &files)
+	if len(files) != 2 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	var events []gentr.Event
+	getJSON(t, server.URL+"/events", http.StatusOK, &events)
+	if len(events) != 1 || events[0].Path != "a.go" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	getJSON(t, server.URL+"/result", http.StatusNotFound, &map[string]string{})
+	controller.result = &gentr.Result{Command: "make", ExitCode: 2, Duration: time.Second}
+	var result Result
+	getJSON(t, server.URL+"/result", http.StatusOK, &result)
+	if result.Command != "make" || result.ExitCode != 2 || result.DurationMS != 1000 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestHandlerDrivesController(t *testing.T) {
+	controller := &fakeController{}
+	server := httptest.NewServer(NewHandler(controller))
+	defer server.Close()
+
+	for path, want := range map[string]int{"/run": http.StatusAccepted, "/pause": http.StatusOK, "/resume": http.StatusOK, "/reload": http.StatusOK} {
+		response, err := http.Post(server.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, response.StatusCode)
+		}
+	}
+	if controller.triggered != 1 || controller.paused != 1 || controller.resumed != 1 {
+		t.Fatalf("unexpected controller calls: %+v", controller)
+	}
+
+	controller.reloadErr = errors.New("bad config")
+	response, err := http.Post(server.URL+"/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected reload failure, got %d", response.StatusCode)
+	}
+
+	controller.reloadErr = gentr.ErrBusy
+	response, err = http.Post(server.URL+"/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusConflict {
+		t.Fatalf("expected a busy watcher to answer 409, got %d", response.StatusCode)
+	}
+
+	response, err = http.Get(server.URL + "/run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET /run to be rejected, got %d", response.StatusCode)
+	}
+}
+
+func TestServeOnUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gentr.sock")
+	listener, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, listener, NewHandler(&fakeController{})) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected serve error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop after cancellation")
+	}
+}
+
+func getJSON(t *testing.T, url string, status int, value any) {
+	t.Helper()
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != status {
+		t.Fatalf("%s: expected status %d, got %d", url, status, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+		t.Fatalf("%s: invalid JSON: %v", url, err)
+	}
+}
+
+func TestListenOnlyBindsLoopback(t *testing.T) {
+	for _, address := range []string{":0", "127.0.0.1:0", "localhost:0", "[::1]:0"} {
+		listener, err := Listen(address)
+		if err != nil {
+			if address == "[::1]:0" {
+				continue
+			}
+			t.Fatalf("%s: %v", address, err)
+		}
+		if ip := listener.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+			t.Fatalf("%s bound %s", address, ip)
+		}
+		listener.Close()
+	}
+	for _, address := range []string{"0.0.0.0:0", "[::]:0", "192.0.2.10:0"} {
+		if listener, err := Listen(address); err == nil {
+			listener.Close()
+			t.Fatalf("%s: expected a non-loopback address to be refused", address)
+		}
+	}
+}
+
+func TestListenReplacesOnlyStaleSockets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gentr.sock")
+	listener, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if listener, err = Listen("unix:" + path); err != nil {
+		t.Fatalf("expected a stale socket to be replaced: %v", err)
+	}
+	listener.Close()
+
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(file, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if listener, err := Listen("unix:" + file); err == nil {
+		listener.Close()
+		t.Fatal("expected a regular file to be refused")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "keep" {
+		t.Fatalf("regular file was modified: %q %v", data, err)
+	}
+}
+
+func TestHandlerRejectsForeignHostsAndSimplePosts(t *testing.T) {
+	controller := &fakeController{}
+	server := httptest.NewServer(NewHandler(controller))
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/status", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Host = "attacker.example:7878"
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a rebound host to be rejected, got %d", response.StatusCode)
+	}
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		response, err := http.Post(server.URL+"/run", contentType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("%q: expected 415, got %d", contentType, response.StatusCode)
+		}
+	}
+	if controller.triggered != 0 {
+		t.Fatalf("expected no run to be triggered, got %d", controller.triggered)
+	}
+
+	for _, host := range []string{"localhost:7878", "127.0.0.1", "[::1]:7878"} {
+		if !loopbackHost(host) {
+			t.Fatalf("expected %s to be accepted", host)
+		}
+	}
+}
+
+func TestHandlerAcceptsAnyHostOnUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gentr.sock")
+	listener, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Serve(ctx, listener, NewHandler(&fakeController{}))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	response, err := client.Get("http://gentr/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected socket requests to be served, got %d", response.StatusCode)
+	}
+}
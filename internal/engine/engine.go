@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/tiendu/gentr/internal/clock"
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/logging"
+	"github.com/tiendu/gentr/internal/metrics"
+	"github.com/tiendu/gentr/internal/watch"
+)
+
+var ErrNoFiles = errors.New("gentr: no files to watch")
+
+type Config struct {
+	Options  config.Options
+	Files    []string
+	Command  string
+	Runner   watch.CommandRunner
+	Reporter watch.OutputReporter
+	Logger   watch.ChangeLogger
+	Resolver input.Resolver
+	Spinner  watch.Spinner
+	Output   io.Writer
+	Metrics  *metrics.Set
+	Git      watch.GitMonitor
+	FS       fsys.FS
+	Clock    clock.Clock
+	Updates  <-chan watch.PathUpdate
+	DebugLog *logging.Logger
+}
+
+type Engine struct {
+	*watch.Watcher
+	files   []string
+	command string
+}
+
+func New(c Config) (*Engine, error) {
+	opts := c.Options
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	opts.Git = opts.Git || c.Git != nil
+	resolver := c.Resolver
+	if resolver == nil {
+		resolver = input.FileResolver{Include: opts.Include, Exclude: opts.Exclude, FS: c.FS, FollowSymlinks: opts.FollowSymlinks}
+	}
+	spinner := c.Spinner
+	if spinner == nil {
+		spinner = quietSpinner{}
+	}
+	debugLog := c.DebugLog
+	if debugLog == nil && opts.Debug {
+		debugLog = logging.New(os.Stderr, logging.LevelDebug)
+	}
+	if _, traced := resolver.(input.TracingResolver); debugLog != nil && !traced {
+		resolver = input.TracingResolver{Next: resolver, Log: debugLog.Named("input")}
+	}
+
+	files := c.Files
+	if len(files) == 0 && c.Updates == nil {
+		resolved, err := input.ResolveRoots(resolver, opts.InputRoots())
+		if err != nil {
+			return nil, err
+		}
+		files = resolved
+	}
+	if len(files) == 0 && c.Updates == nil {
+		return nil, ErrNoFiles
+	}
+
+	watcher := watch.New(opts, spinner, c.Runner, c.Reporter, c.Logger, resolver, c.Output)
+	watcher.SetMetrics(c.Metrics)
+	watcher.SetFileSystem(c.FS)
+	watcher.SetClock(c.Clock)
+	watcher.SetDebugLogger(debugLog)
+	if c.Git != nil {
+		watcher.SetGit(c.Git)
+	}
+	if c.Updates != nil {
+		watcher.SetPathUpdates(c.Updates)
+	}
+	return &Engine{Watcher: watcher, files: files, command: c.Command}, nil
+}
+
+func (e *Engine) Run(ctx context.Context) int {
+	return e.Watcher.Run(ctx, e.files, e.command)
+}
+
+type quietSpinner struct{}
+
+func (quietSpinner) Start()  {}
+func (quietSpinner) Stop()   {}
+func (quietSpinner) Pause()  {}
+func (quietSpinner) Resume() {}
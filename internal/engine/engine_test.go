@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/runner"
+	"github.com/tiendu/gentr/internal/watch"
+)
+
+type recordingRunner struct{ files []string }
+
+func (r *recordingRunner) Run(command, file string) runner.Result {
+	r.files = append(r.files, file)
+	return runner.Result{Command: command, File: file}
+}
+
+func TestNewResolvesRootsAndRuns(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	memory.WriteFile("src/a.go", nil)
+	memory.WriteFile("src/a_test.go", nil)
+
+	opts := config.New(false, false, "src", 0, false)
+	opts.Exclude = []string{"*_test.go"}
+	opts.Once = true
+	commandRunner := &recordingRunner{}
+	engine, err := New(Config{Options: opts, Command: "go test", Runner: commandRunner, FS: memory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := engine.Run(context.Background()); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if len(commandRunner.files) != 1 || commandRunner.files[0] != "src/a.go" {
+		t.Fatalf("unexpected runner calls: %v", commandRunner.files)
+	}
+}
+
+func TestNewRejectsInvalidOptionsAndEmptyInput(t *testing.T) {
+	opts := config.New(false, false, "empty", 0, false)
+	opts.PollInterval = 0
+	if _, err := New(Config{Options: opts, Files: []string{"a.go"}}); err == nil {
+		t.Fatal("expected an error for a zero poll interval")
+	}
+
+	memory := fsys.NewMemory(nil)
+	memory.Mkdir("empty")
+	opts.PollInterval = time.Second
+	if _, err := New(Config{Options: opts, FS: memory}); !errors.Is(err, ErrNoFiles) {
+		t.Fatalf("expected ErrNoFiles, got %v", err)
+	}
+	if _, err := New(Config{Options: opts, FS: memory, Updates: make(chan watch.PathUpdate)}); err != nil {
+		t.Fatalf("expected streamed input to start without files, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package fsys
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiendu/gentr/internal/clock"
+)
+
+type FS interface {
+	fs.StatFS
+	fs.ReadFileFS
+	fs.ReadDirFS
+	Lstat(name string) (fs.FileInfo, error)
+	ReadLink(name string) (string, error)
+}
+
+const maxLinks = 255
+
+type OS struct{}
+
+func (OS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (OS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (OS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (OS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (OS) Glob(pattern string) ([]string, error)      { return filepath.Glob(pattern) }
+func (OS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (OS) ReadLink(name string) (string, error)       { return os.Readlink(name) }
+
+func Or(fsys FS) FS {
+	if fsys == nil {
+		return OS{}
+	}
+	return fsys
+}
+
+type Memory struct {
+	mutex   sync.Mutex
+	clock   clock.Clock
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	target  string
+}
+
+func NewMemory(c clock.Clock) *Memory {
+	if c == nil {
+		c = clock.Real{}
+	}
+	return &Memory{clock: c, entries: make(map[string]*memoryEntry)}
+}
+
+func (m *Memory) WriteFile(name string, data []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	name = filepath.Clean(name)
+	m.mkdirAll(filepath.Dir(name))
+	mode := fs.FileMode(0o644)
+	if entry, exists := m.entries[name]; exists {
+		mode = entry.mode
+	} else {
+		m.touchParent(name)
+	}
+	m.entries[name] = &memoryEntry{data: append([]byte(nil), data...), mode: mode, modTime: m.clock.Now()}
+}
+
+func (m *Memory) Chmod(name string, mode fs.FileMode) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, entry, err := m.follow("chmod", name); err == nil {
+		entry.mode = entry.mode&fs.ModeType | mode.Perm()
+	}
+}
+
+func (m *Memory) Mkdir(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.mkdirAll(filepath.Clean(name))
+}
+
+func (m *Memory) Touch(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, entry, err := m.follow("chtimes", name); err == nil {
+		entry.modTime = m.clock.Now()
+	}
+}
+
+func (m *Memory) Remove(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	name = filepath.Clean(name)
+	for path := range m.entries {
+		if path == name || Within(name, path) {
+			delete(m.entries, path)
+		}
+	}
+	m.touchParent(name)
+}
+
+func (m *Memory) Symlink(target, name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	name = filepath.Clean(name)
+	m.mkdirAll(filepath.Dir(name))
+	m.touchParent(name)
+	m.entries[name] = &memoryEntry{mode: fs.ModeSymlink | 0o777, modTime: m.clock.Now(), target: target}
+}
+
+func (m *Memory) Open(name string) (fs.File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	resolved, entry, err := m.follow("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := memoryInfo{name: filepath.Base(name), entry: *entry}
+	if entry.mode.IsDir() {
+		return &memoryDir{info: info, entries: m.readDir(resolved)}, nil
+	}
+	return &memoryFile{info: info, reader: bytes.NewReader(entry.data)}, nil
+}
+
+func (m *Memory) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, entry, err := m.follow("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return memoryInfo{name: filepath.Base(name), entry: *entry}, nil
+}
+
+func (m *Memory) Lstat(name string) (fs.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, err := m.lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	return memoryInfo{name: filepath.Base(name), entry: *entry}, nil
+}
+
+func (m *Memory) ReadLink(name string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, err := m.lstat(name)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return entry.target, nil
+}
+
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, entry, err := m.follow("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return append([]byte(nil), entry.data...), nil
+}
+
+func (m *Memory) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	resolved, entry, err := m.follow("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return m.readDir(resolved), nil
+}
+
+func (m *Memory) follow(op, name string) (string, *memoryEntry, error) {
+	resolved, err := evalSymlinks(m.lstatInfo, m.readLink, name)
+	if err != nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: unwrap(err)}
+	}
+	return resolved, m.entries[resolved], nil
+}
+
+func (m *Memory) lstat(name string) (*memoryEntry, error) {
+	name = filepath.Clean(name)
+	parent, err := evalSymlinks(m.lstatInfo, m.readLink, filepath.Dir(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: unwrap(err)}
+	}
+	entry, ok := m.entries[filepath.Join(parent, filepath.Base(name))]
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+func (m *Memory) lstatInfo(name string) (fs.FileInfo, error) {
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryInfo{name: filepath.Base(name), entry: *entry}, nil
+}
+
+func (m *Memory) readLink(name string) (string, error) {
+	return m.entries[name].target, nil
+}
+
+func EvalSymlinks(fileSystem FS, name string) (string, error) {
+	return evalSymlinks(fileSystem.Lstat, fileSystem.ReadLink, name)
+}
+
+func evalSymlinks(lstat func(string) (fs.FileInfo, error), readLink func(string) (string, error), name string) (string, error) {
+	separator := string(filepath.Separator)
+	resolved := ""
+	if filepath.IsAbs(name) {
+		resolved = separator
+	}
+	pending := strings.Split(filepath.Clean(name), separator)
+	links := 0
+	for len(pending) > 0 {
+		part := pending[0]
+		pending = pending[1:]
+		if part == "" || part == "." {
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		if part == ".." {
+			resolved = next
+			continue
+		}
+		info, err := lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxLinks {
+			return "", &fs.PathError{Op: "eval symlinks", Path: name, Err: errTooManyLinks}
+		}
+		target, err := readLink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = separator
+		}
+		pending = append(strings.Split(filepath.Clean(target), separator), pending...)
+	}
+	if resolved == "" {
+		return ".", nil
+	}
+	return resolved, nil
+}
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+func unwrap(err error) error {
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) {
+		return pathError.Err
+	}
+	return err
+}
+
+func (m *Memory) readDir(name string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0)
+	for path, entry := range m.entries {
+		if path != name && filepath.Dir(path) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memoryInfo{name: filepath.Base(path), entry: *entry}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+func (m *Memory) mkdirAll(name string) {
+	for {
+		if entry, ok := m.entries[name]; ok && entry.mode.IsDir() {
+			return
+		}
+		m.entries[name] = &memoryEntry{mode: fs.ModeDir | 0o755, modTime: m.clock.Now()}
+		m.touchParent(name)
+		parent := filepath.Dir(name)
+		if parent == name {
+			return
+		}
+		name = parent
+	}
+}
+
+func (m *Memory) touchParent(name string) {
+	if parent, ok := m.entries[filepath.Dir(name)]; ok && filepath.Dir(name) != name {
+		parent.modTime = m.clock.Now()
+	}
+}
+
+func Within(directory, path string) bool {
+	if directory == "." {
+		return path != "." && !filepath.IsAbs(path) && !strings.HasPrefix(path, "..")
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(directory, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type memoryInfo struct {
+	name  string
+	entry memoryEntry
+}
+
+func (i memoryInfo) Name() string       { return i.name }
+func (i memoryInfo) Size() int64        { return int64(len(i.entry.data)) }
+func (i memoryInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i memoryInfo) ModTime() time.Time { return i.entry.modTime }
+func (i memoryInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i memoryInfo) Sys() any           { return nil }
+
+type memoryFile struct {
+	info   memoryInfo
+	reader *bytes.Reader
+}
+
+func (f *memoryFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memoryFile) Read(p []byte) (int, error) { return f.reader.Read(p) }
+func (f *memoryFile) Close() error               { return nil }
+
+type memoryDir struct {
+	info    memoryInfo
+	entries []fs.DirEntry
+}
+
+func (d *memoryDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memoryDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+func (d *memoryDir) Close() error { return nil }
+
+func (d *memoryDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
@@ -0,0 +1,126 @@
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/clock"
+)
+
+func TestMemoryStatsReadsAndTouchesFiles(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	memory := NewMemory(fake)
+	memory.WriteFile("src/a.go", []byte("package a\n"))
+
+	info, err := memory.Stat("src/a.go")
+	if err != nil || info.IsDir() || info.Size() != 10 || !info.ModTime().Equal(fake.Now()) {
+		t.Fatalf("info=%+v err=%v", info, err)
+	}
+	if info, err := memory.Stat("src"); err != nil || !info.IsDir() {
+		t.Fatalf("expected parent directory, info=%+v err=%v", info, err)
+	}
+	if data, err := fs.ReadFile(memory, "src/a.go"); err != nil || string(data) != "package a\n" {
+		t.Fatalf("data=%q err=%v", data, err)
+	}
+
+	fake.Advance(time.Second)
+	memory.Touch("src/a.go")
+	if info, _ := memory.Stat("src/a.go"); !info.ModTime().Equal(fake.Now()) {
+		t.Fatalf("touch did not update modtime: %v", info.ModTime())
+	}
+
+	fake.Advance(time.Second)
+	memory.WriteFile("src/b.go", nil)
+	if info, _ := memory.Stat("src"); !info.ModTime().Equal(fake.Now()) {
+		t.Fatalf("adding a file should update the directory modtime: %v", info.ModTime())
+	}
+
+	memory.Remove("src")
+	if _, err := memory.Stat("src/a.go"); !errors.Is(err, fs.ErrNotExist) || !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestMemoryWorksWithWalkDirAndGlob(t *testing.T) {
+	memory := NewMemory(nil)
+	for _, name := range []string{"/w/b.txt", "/w/a.go", "/w/sub/c.go"} {
+		memory.WriteFile(name, nil)
+	}
+
+	walked := make([]string, 0)
+	err := fs.WalkDir(memory, "/w", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, path)
+		return nil
+	})
+	want := []string{"/w", "/w/a.go", "/w/b.txt", "/w/sub", "/w/sub/c.go"}
+	if err != nil || !reflect.DeepEqual(walked, want) {
+		t.Fatalf("walked=%v err=%v", walked, err)
+	}
+
+	matches, err := fs.Glob(memory, "/w/*.go")
+	if err != nil || !reflect.DeepEqual(matches, []string{"/w/a.go"}) {
+		t.Fatalf("matches=%v err=%v", matches, err)
+	}
+}
+
+func TestMemoryFollowsSymlinks(t *testing.T) {
+	memory := NewMemory(nil)
+	memory.WriteFile("vendor/lib/a.go", []byte("package lib\n"))
+	memory.Symlink("../vendor/lib", "src/lib")
+	memory.Symlink("loop", "src/loop")
+
+	if data, err := memory.ReadFile("src/lib/a.go"); err != nil || string(data) != "package lib\n" {
+		t.Fatalf("data=%q err=%v", data, err)
+	}
+	if info, err := memory.Lstat("src/lib"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("expected a symlink, info=%+v err=%v", info, err)
+	}
+	if info, err := memory.Stat("src/lib"); err != nil || !info.IsDir() {
+		t.Fatalf("expected the link target directory, info=%+v err=%v", info, err)
+	}
+	if target, err := memory.ReadLink("src/lib"); err != nil || target != "../vendor/lib" {
+		t.Fatalf("target=%q err=%v", target, err)
+	}
+	if real, err := EvalSymlinks(memory, "src/lib/a.go"); err != nil || real != "vendor/lib/a.go" {
+		t.Fatalf("real=%q err=%v", real, err)
+	}
+	if _, err := memory.Stat("src/loop"); err == nil {
+		t.Fatal("expected an error for a symlink loop")
+	}
+}
+
+func TestOSReadsRealFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var system FS = Or(nil)
+	if data, err := system.ReadFile(path); err != nil || string(data) != "hello" {
+		t.Fatalf("data=%q err=%v", data, err)
+	}
+	if entries, err := system.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("entries=%v err=%v", entries, err)
+	}
+	if matches, err := fs.Glob(system, filepath.Join(dir, "*.txt")); err != nil || len(matches) != 1 {
+		t.Fatalf("matches=%v err=%v", matches, err)
+	}
+
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("a.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.EvalSymlinks(link)
+	if real, err := EvalSymlinks(system, link); err != nil || real != want {
+		t.Fatalf("real=%q want=%q err=%v", real, want, err)
+	}
+}
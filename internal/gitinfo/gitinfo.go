@@ -0,0 +1,186 @@
+package gitinfo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	inputpkg "github.com/tiendu/gentr/internal/input"
+)
+
+type State struct {
+	Branch string
+	Commit string
+}
+
+func (s State) String() string {
+	commit := s.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("%s@%s", s.Branch, commit)
+}
+
+type Repo struct {
+	Dir    string
+	GitDir string
+}
+
+func Open(dir string) (Repo, error) {
+	output, err := git(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return Repo{}, fmt.Errorf("open git repository: %w", err)
+	}
+	return Repo{Dir: dir, GitDir: strings.TrimSpace(output)}, nil
+}
+
+func (r Repo) Files(pathspec string) ([]string, error) {
+	args := []string{"ls-files", "--cached", "--others", "--exclude-standard", "-z"}
+	if pathspec != "" && pathspec != "." {
+		args = append(args, "--", pathspec)
+	}
+	output, err := git(r.Dir, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list git files: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	files := make([]string, 0)
+	for _, path := range strings.Split(output, "\x00") {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if info, err := os.Lstat(filepath.Join(r.Dir, path)); err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (r Repo) State() (State, error) {
+	branch, err := git(r.Dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return State{}, fmt.Errorf("read git branch: %w", err)
+	}
+	commit, err := git(r.Dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		commit = ""
+	}
+	return State{Branch: strings.TrimSpace(branch), Commit: strings.TrimSpace(commit)}, nil
+}
+
+func (r Repo) StatePaths(state State) []string {
+	paths := []string{
+		filepath.Join(r.GitDir, "HEAD"),
+		filepath.Join(r.GitDir, "packed-refs"),
+	}
+	if state.Branch != "" && state.Branch != "HEAD" {
+		paths = append(paths, filepath.Join(r.GitDir, "refs", "heads", filepath.FromSlash(state.Branch)))
+	}
+	return paths
+}
+
+type Resolver struct {
+	Repo   Repo
+	Filter inputpkg.FileResolver
+}
+
+func (r Resolver) Resolve(input string, _ bool) ([]string, error) {
+	files, err := r.Repo.Files(input)
+	if err != nil {
+		return nil, err
+	}
+	return r.Filter.Filter(files).Files, nil
+}
+
+func (r Resolver) ResolveRoot(root config.Root) ([]string, error) {
+	return Resolver{Repo: r.Repo, Filter: r.Filter.ForRoot(root)}.Resolve(root.Path, root.Recursive)
+}
+
+type Monitor struct {
+	repo   Repo
+	state  State
+	stamps map[string]time.Time
+}
+
+func NewMonitor(repo Repo) (*Monitor, error) {
+	state, err := repo.State()
+	if err != nil {
+		return nil, err
+	}
+	monitor := &Monitor{repo: repo, state: state}
+	monitor.stamps = monitor.readStamps()
+	return monitor, nil
+}
+
+func (m *Monitor) State() State {
+	return m.state
+}
+
+func (m *Monitor) HeadPath() string {
+	return filepath.Join(m.repo.GitDir, "HEAD")
+}
+
+func (m *Monitor) Poll() (State, bool, error) {
+	stamps := m.readStamps()
+	if sameStamps(stamps, m.stamps) {
+		return m.state, false, nil
+	}
+	m.stamps = stamps
+
+	state, err := m.repo.State()
+	if err != nil {
+		return m.state, false, err
+	}
+	previous := m.state
+	m.state = state
+	m.stamps = m.readStamps()
+	return previous, previous != state, nil
+}
+
+func (m *Monitor) readStamps() map[string]time.Time {
+	stamps := make(map[string]time.Time)
+	for _, path := range m.repo.StatePaths(m.state) {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = info.ModTime()
+		}
+	}
+	return stamps
+}
+
+func sameStamps(left, right map[string]time.Time) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for path, stamp := range left {
+		if other, ok := right[path]; !ok || !other.Equal(stamp) {
+			return false
+		}
+	}
+	return true
+}
+
+func Expand(command string, state State) string {
+	return strings.NewReplacer("/git_branch", state.Branch, "/git_commit", state.Commit).Replace(command)
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
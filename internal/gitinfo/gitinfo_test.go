@@ -0,0 +1,121 @@
+package gitinfo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	inputpkg "github.com/tiendu/gentr/internal/input"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch=main")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "test")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(dir, "tracked.go"), "package a\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "--quiet", "-m", "initial")
+	return dir
+}
+
+func TestRepoFilesListsTrackedAndUntracked(t *testing.T) {
+	dir := initRepo(t)
+	writeFile(t, filepath.Join(dir, "untracked.go"), "package a\n")
+	writeFile(t, filepath.Join(dir, "ignored.log"), "noise\n")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := repo.Files(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".gitignore", "tracked.go", "untracked.go"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("expected %v, got %v", want, files)
+	}
+
+	resolver := Resolver{Repo: repo, Filter: inputpkg.FileResolver{Include: []string{"*.go"}}}
+	files, err = resolver.Resolve(".", false)
+	if err != nil || !reflect.DeepEqual(files, []string{"tracked.go", "untracked.go"}) {
+		t.Fatalf("files=%v err=%v", files, err)
+	}
+}
+
+func TestMonitorDetectsBranchSwitchAndCommit(t *testing.T) {
+	dir := initRepo(t)
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitor, err := NewMonitor(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := monitor.State()
+	if initial.Branch != "main" || len(initial.Commit) != 40 {
+		t.Fatalf("unexpected initial state: %+v", initial)
+	}
+	if _, changed, err := monitor.Poll(); changed || err != nil {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+
+	runGit(t, dir, "checkout", "--quiet", "-b", "feature")
+	bumpModTime(t, filepath.Join(repo.GitDir, "HEAD"))
+	previous, changed, err := monitor.Poll()
+	if err != nil || !changed || previous.Branch != "main" || monitor.State().Branch != "feature" {
+		t.Fatalf("previous=%+v state=%+v changed=%v err=%v", previous, monitor.State(), changed, err)
+	}
+
+	writeFile(t, filepath.Join(dir, "tracked.go"), "package b\n")
+	runGit(t, dir, "commit", "--quiet", "-am", "change")
+	bumpModTime(t, filepath.Join(repo.GitDir, "refs", "heads", "feature"))
+	previous, changed, err = monitor.Poll()
+	if err != nil || !changed || previous.Commit == monitor.State().Commit {
+		t.Fatalf("previous=%+v state=%+v changed=%v err=%v", previous, monitor.State(), changed, err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	got := Expand("deploy /git_branch /git_commit /_", State{Branch: "main", Commit: "abc"})
+	if got != "deploy main abc /_" {
+		t.Fatalf("unexpected expansion: %q", got)
+	}
+	if got := (State{Branch: "main", Commit: strings.Repeat("a", 40)}).String(); got != "main@aaaaaaaaaaaa" {
+		t.Fatalf("unexpected state string: %q", got)
+	}
+}
+
+func bumpModTime(t *testing.T, path string) {
+	t.Helper()
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,243 @@
+package gopkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+const Pattern = "./..."
+
+type Package struct {
+	ImportPath   string
+	Dir          string
+	Standard     bool
+	Deps         []string
+	TestImports  []string
+	XTestImports []string
+	Module       *struct {
+		Path string
+		Main bool
+	}
+}
+
+type Graph struct {
+	byDir   map[string]string
+	reverse map[string]map[string]bool
+	local   map[string]bool
+}
+
+func ParseList(reader io.Reader) (*Graph, error) {
+	graph := &Graph{
+		byDir:   make(map[string]string),
+		reverse: make(map[string]map[string]bool),
+		local:   make(map[string]bool),
+	}
+
+	deps := make(map[string][]string)
+	packages := make([]Package, 0)
+	decoder := json.NewDecoder(reader)
+	for decoder.More() {
+		var pkg Package
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("decode go list output: %w", err)
+		}
+		if pkg.Standard || pkg.Module == nil || !pkg.Module.Main {
+			continue
+		}
+
+		graph.local[pkg.ImportPath] = true
+		graph.byDir[filepath.Clean(pkg.Dir)] = pkg.ImportPath
+		deps[pkg.ImportPath] = pkg.Deps
+		packages = append(packages, pkg)
+	}
+
+	for _, pkg := range packages {
+		imports := [][]string{pkg.Deps, pkg.TestImports, pkg.XTestImports}
+		for _, tests := range [][]string{pkg.TestImports, pkg.XTestImports} {
+			for _, imported := range tests {
+				imports = append(imports, deps[imported])
+			}
+		}
+		for _, list := range imports {
+			for _, dependency := range list {
+				if graph.reverse[dependency] == nil {
+					graph.reverse[dependency] = make(map[string]bool)
+				}
+				graph.reverse[dependency][pkg.ImportPath] = true
+			}
+		}
+	}
+	return graph, nil
+}
+
+func (g *Graph) Affected(files []string) ([]string, bool) {
+	affected := make(map[string]bool)
+	for _, file := range files {
+		switch filepath.Base(file) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			return nil, false
+		}
+
+		absolute, err := filepath.Abs(file)
+		if err != nil {
+			return nil, false
+		}
+		importPath, ok := g.byDir[filepath.Dir(absolute)]
+		if !ok {
+			if filepath.Ext(file) == ".go" {
+				return nil, false
+			}
+			continue
+		}
+
+		affected[importPath] = true
+		for dependent := range g.reverse[importPath] {
+			if g.local[dependent] {
+				affected[dependent] = true
+			}
+		}
+	}
+	if len(affected) == 0 {
+		return nil, false
+	}
+
+	packages := make([]string, 0, len(affected))
+	for importPath := range affected {
+		packages = append(packages, importPath)
+	}
+	sort.Strings(packages)
+	return packages, true
+}
+
+func ListPackages() (*Graph, error) {
+	cmd := exec.Command("go", "list", "-deps", "-test=false", "-json", Pattern)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return ParseList(bytes.NewReader(output))
+}
+
+type Cache struct {
+	mutex sync.Mutex
+	graph *Graph
+	stale string
+}
+
+func (c *Cache) Graph(files []string, load func() (*Graph, error)) (*Graph, error) {
+	if c == nil {
+		return load()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.graph != nil {
+		stale := c.graph.stale(files)
+		if stale == "" || stale == c.stale {
+			return c.graph, nil
+		}
+	}
+	graph, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.graph = graph
+	c.stale = graph.stale(files)
+	return graph, nil
+}
+
+func (g *Graph) stale(files []string) string {
+	reasons := make([]string, 0)
+	for _, file := range files {
+		switch filepath.Base(file) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			modified := ""
+			if info, err := os.Stat(file); err == nil {
+				modified = info.ModTime().String()
+			}
+			reasons = append(reasons, file+"@"+modified)
+			continue
+		}
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		absolute, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		if _, ok := g.byDir[filepath.Dir(absolute)]; !ok {
+			reasons = append(reasons, filepath.Dir(absolute))
+		}
+	}
+	return strings.Join(reasons, "\x00")
+}
+
+type CommandRunner interface {
+	Run(command, file string) runner.Result
+}
+
+type HookRunner interface {
+	RunHook(command, file string, main *runner.Result) runner.Result
+}
+
+type Runner struct {
+	Next   CommandRunner
+	Load   func() (*Graph, error)
+	Cache  *Cache
+	Output io.Writer
+}
+
+func (r Runner) Run(command, file string) runner.Result {
+	return r.RunFiles(command, []string{file})
+}
+
+func (r Runner) RunFiles(command string, files []string) runner.Result {
+	return runner.RunFiles(r.Next, r.Rewrite(command, files), files)
+}
+
+func (r Runner) RunHook(command, file string, main *runner.Result) runner.Result {
+	if hookRunner, ok := r.Next.(HookRunner); ok {
+		return hookRunner.RunHook(command, file, main)
+	}
+	return r.Next.Run(command, file)
+}
+
+func (r Runner) Rewrite(command string, files []string) string {
+	output := r.Output
+	if output == nil {
+		output = io.Discard
+	}
+	if !strings.Contains(command, Pattern) {
+		return command
+	}
+
+	load := r.Load
+	if load == nil {
+		load = ListPackages
+	}
+	graph, err := r.Cache.Graph(files, load)
+	if err != nil {
+		fmt.Fprintf(output, "\n[x] Error loading Go packages, running all packages: %v\n", err)
+		return command
+	}
+	packages, ok := graph.Affected(files)
+	if !ok {
+		fmt.Fprintln(output, "\n[!] Change affects the whole module, running all packages")
+		return command
+	}
+
+	fmt.Fprintf(output, "\nAffected packages: %s\n", strings.Join(packages, " "))
+	return strings.ReplaceAll(command, Pattern, strings.Join(packages, " "))
+}
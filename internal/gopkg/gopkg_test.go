@@ -0,0 +1,151 @@
+package gopkg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+func testGraph(t *testing.T, root string) *Graph {
+	t.Helper()
+	list := fmt.Sprintf(`{"ImportPath": "fmt", "Dir": "/usr/lib/go/src/fmt", "Standard": true}
+{"ImportPath": "example.com/app/store", "Dir": %q, "Deps": ["fmt"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/api", "Dir": %q, "Deps": ["example.com/app/store", "fmt"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/cmd", "Dir": %q, "Deps": ["example.com/app/api", "example.com/app/store"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/util", "Dir": %q, "XTestImports": ["example.com/app/store"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/lib", "Dir": "/mod/lib", "Module": {"Path": "example.com/lib"}}
+`,
+		filepath.Join(root, "store"), filepath.Join(root, "api"), filepath.Join(root, "cmd"), filepath.Join(root, "util"))
+
+	graph, err := ParseList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph
+}
+
+func TestGraphAffectedIncludesReverseDependencies(t *testing.T) {
+	root := t.TempDir()
+	graph := testGraph(t, root)
+
+	got, ok := graph.Affected([]string{filepath.Join(root, "store", "store.go")})
+	want := "example.com/app/api example.com/app/cmd example.com/app/store example.com/app/util"
+	if !ok || strings.Join(got, " ") != want {
+		t.Fatalf("got=%v ok=%v", got, ok)
+	}
+
+	got, ok = graph.Affected([]string{filepath.Join(root, "cmd", "main.go")})
+	if !ok || strings.Join(got, " ") != "example.com/app/cmd" {
+		t.Fatalf("got=%v ok=%v", got, ok)
+	}
+}
+
+func TestGraphAffectedFollowsDependenciesOfTestImports(t *testing.T) {
+	root := t.TempDir()
+	list := fmt.Sprintf(`{"ImportPath": "example.com/app/a", "Dir": %q, "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/b", "Dir": %q, "Deps": ["example.com/app/a"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/c", "Dir": %q, "XTestImports": ["example.com/app/b"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/d", "Dir": %q, "TestImports": ["example.com/app/b"], "Module": {"Path": "example.com/app", "Main": true}}
+{"ImportPath": "example.com/app/e", "Dir": %q, "Deps": ["example.com/app/c"], "Module": {"Path": "example.com/app", "Main": true}}
+`, filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "c"), filepath.Join(root, "d"), filepath.Join(root, "e"))
+	graph, err := ParseList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := graph.Affected([]string{filepath.Join(root, "a", "a.go")})
+	want := "example.com/app/a example.com/app/b example.com/app/c example.com/app/d"
+	if !ok || strings.Join(got, " ") != want {
+		t.Fatalf("got=%v ok=%v, want %s", got, ok, want)
+	}
+}
+
+func TestGraphAffectedFallsBackToWholeModule(t *testing.T) {
+	root := t.TempDir()
+	graph := testGraph(t, root)
+
+	for _, files := range [][]string{
+		{filepath.Join(root, "go.mod")},
+		{filepath.Join(root, "newpkg", "new.go")},
+		{filepath.Join(root, "README.md")},
+	} {
+		if got, ok := graph.Affected(files); ok {
+			t.Fatalf("%v: expected fallback, got %v", files, got)
+		}
+	}
+}
+
+type fakeRunner struct{ commands []string }
+
+func (r *fakeRunner) Run(command, file string) runner.Result {
+	r.commands = append(r.commands, command)
+	return runner.Result{Command: command, File: file}
+}
+
+func TestRunnerRewritesPattern(t *testing.T) {
+	root := t.TempDir()
+	next := &fakeRunner{}
+	commandRunner := Runner{Next: next, Load: func() (*Graph, error) { return testGraph(t, root), nil }}
+
+	commandRunner.Run("go test ./...", filepath.Join(root, "api", "api.go"))
+	commandRunner.Run("go vet /_", filepath.Join(root, "api", "api.go"))
+	commandRunner.Run("go test ./...", filepath.Join(root, "go.mod"))
+
+	want := []string{"go test example.com/app/api example.com/app/cmd", "go vet /_", "go test ./..."}
+	if strings.Join(next.commands, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected commands: %q", next.commands)
+	}
+}
+
+func TestRunnerMapsEveryFileWithSpacesInPaths(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "my app")
+	next := &fakeRunner{}
+	commandRunner := Runner{Next: next, Load: func() (*Graph, error) { return testGraph(t, root), nil }}
+
+	commandRunner.RunFiles("go test ./...", []string{filepath.Join(root, "cmd", "main.go"), filepath.Join(root, "util", "util.go")})
+	if len(next.commands) != 1 || next.commands[0] != "go test example.com/app/cmd example.com/app/util" {
+		t.Fatalf("unexpected commands: %q", next.commands)
+	}
+}
+
+func TestRunnerKeepsCommandWhenLoadingFails(t *testing.T) {
+	next := &fakeRunner{}
+	commandRunner := Runner{Next: next, Load: func() (*Graph, error) { return nil, fmt.Errorf("no module") }}
+	commandRunner.Run("go test ./...", "a.go")
+	if len(next.commands) != 1 || next.commands[0] != "go test ./..." {
+		t.Fatalf("unexpected commands: %q", next.commands)
+	}
+}
+
+func TestRunnerCachesGraphUntilModuleOrPackagesChange(t *testing.T) {
+	root := t.TempDir()
+	loads := 0
+	next := &fakeRunner{}
+	commandRunner := Runner{Next: next, Cache: &Cache{}, Load: func() (*Graph, error) {
+		loads++
+		return testGraph(t, root), nil
+	}}
+
+	api := filepath.Join(root, "api", "api.go")
+	commandRunner.Run("go test ./...", api)
+	commandRunner.Run("go vet ./...", api)
+	commandRunner.Run("go test ./...", filepath.Join(root, "cmd", "main.go"))
+	if loads != 1 {
+		t.Fatalf("expected the graph to be loaded once, got %d loads", loads)
+	}
+
+	commandRunner.Run("go test ./...", filepath.Join(root, "go.mod"))
+	commandRunner.Run("go vet ./...", filepath.Join(root, "go.mod"))
+	if loads != 2 {
+		t.Fatalf("expected go.mod to reload the graph once, got %d loads", loads)
+	}
+
+	commandRunner.Run("go test ./...", filepath.Join(root, "newpkg", "new.go"))
+	commandRunner.Run("go vet ./...", filepath.Join(root, "newpkg", "new.go"))
+	if loads != 3 {
+		t.Fatalf("expected a new package directory to reload the graph once, got %d loads", loads)
+	}
+}
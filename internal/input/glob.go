@@ -0,0 +1,214 @@
+package input
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tiendu/gentr/internal/fsys"
+)
+
+func isPattern(value string) bool {
+	return strings.ContainsAny(value, "*?[") || len(expandBraces(value)) > 1
+}
+
+func match(pattern, name string) (bool, error) {
+	for _, expanded := range expandBraces(pattern) {
+		segments := splitSegments(expanded)
+		if err := validateSegments(segments); err != nil {
+			return false, err
+		}
+		if matchSegments(segments, splitSegments(name)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func expandBraces(pattern string) []string {
+	start, end := findBraces(pattern)
+	if start < 0 {
+		return []string{pattern}
+	}
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	alternatives := splitAlternatives(pattern[start+1 : end])
+	expanded := make([]string, 0)
+	if len(alternatives) < 2 {
+		for _, inner := range expandBraces(pattern[start+1 : end]) {
+			for _, rest := range expandBraces(suffix) {
+				expanded = append(expanded, prefix+"{"+inner+"}"+rest)
+			}
+		}
+		return expanded
+	}
+	for _, alternative := range alternatives {
+		expanded = append(expanded, expandBraces(prefix+alternative+suffix)...)
+	}
+	return expanded
+}
+
+func glob(fileSystem fsys.FS, pattern string, recursive, follow bool) ([]string, error) {
+	seen := make(map[string]bool)
+	matches := make([]string, 0)
+	for _, expanded := range expandBraces(pattern) {
+		expanded = filepath.ToSlash(filepath.Clean(expanded))
+		segments := splitSegments(expanded)
+		if err := validateSegments(segments); err != nil {
+			return nil, err
+		}
+		if recursive && !strings.Contains(expanded, "**") && len(segments) > 0 {
+			segments = append(segments[:len(segments)-1:len(segments)-1], "**", segments[len(segments)-1])
+			expanded = strings.Join(segments, "/")
+		}
+
+		found, err := globOne(fileSystem, expanded, segments, follow)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range found {
+			if !seen[match] {
+				seen[match] = true
+				matches = append(matches, match)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func globOne(fileSystem fsys.FS, pattern string, segments []string, follow bool) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return fs.Glob(fileSystem, filepath.FromSlash(strings.Join(segments, "/")))
+	}
+
+	base := staticBase(segments)
+	if info, err := fileSystem.Stat(base); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	candidates, err := walkFiles(fileSystem, base, follow)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]string, 0)
+	for _, candidate := range candidates {
+		if matchSegments(segments, splitSegments(candidate)) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches, nil
+}
+
+func staticBase(segments []string) string {
+	static := make([]string, 0)
+	for _, segment := range segments {
+		if isPattern(segment) {
+			break
+		}
+		static = append(static, segment)
+	}
+	if len(static) == 0 {
+		return "."
+	}
+	base := strings.Join(static, "/")
+	if base == "" {
+		return string(filepath.Separator)
+	}
+	return filepath.FromSlash(base)
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func splitSegments(value string) []string {
+	value = filepath.ToSlash(filepath.Clean(value))
+	if value == "." {
+		return []string{}
+	}
+	segments := strings.Split(value, "/")
+	for i, segment := range segments {
+		segments[i] = normalizeClasses(segment)
+	}
+	return segments
+}
+
+func validateSegments(segments []string) error {
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid pattern segment %q: %w", segment, err)
+		}
+	}
+	return nil
+}
+
+func normalizeClasses(segment string) string {
+	for segment != "**" && strings.Contains(segment, "**") {
+		segment = strings.ReplaceAll(segment, "**", "*")
+	}
+	return strings.ReplaceAll(segment, "[!", "[^")
+}
+
+func findBraces(pattern string) (int, int) {
+	start, depth := -1, 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				return start, i
+			}
+		}
+	}
+	return -1, -1
+}
+
+func splitAlternatives(body string) []string {
+	alternatives := make([]string, 0)
+	depth, last := 0, 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, body[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alternatives, body[last:])
+}
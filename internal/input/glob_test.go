@@ -0,0 +1,100 @@
+package input
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/fsys"
+)
+
+func TestMatchSupportsDoublestarBracesAndClasses(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"src/**/*.ts", "src/a.ts", true},
+		{"src/**/*.ts", "src/app/view/b.ts", true},
+		{"src/**/*.ts", "lib/a.ts", false},
+		{"**", "a/b/c", true},
+		{"**/testdata/**", "pkg/testdata/x.json", true},
+		{"*.{ts,tsx}", "view.tsx", true},
+		{"*.{ts,tsx}", "view.js", false},
+		{"{src,lib/{a,b}}/*.go", "lib/b/x.go", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"file[!0-9].txt", "fileA.txt", true},
+		{"./src/*.go", "src/main.go", true},
+	}
+	for _, test := range tests {
+		got, err := match(test.pattern, test.name)
+		if err != nil || got != test.want {
+			t.Errorf("match(%q, %q) = %v, %v; want %v", test.pattern, test.name, got, err, test.want)
+		}
+	}
+	if _, err := match("src/[a-", "src/a"); err == nil {
+		t.Error("expected an error for an unterminated class")
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	got := expandBraces("{a,b{1,2}}.go")
+	want := []string{"a.go", "b1.go", "b2.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := expandBraces("plain{.go"); !reflect.DeepEqual(got, []string{"plain{.go"}) {
+		t.Fatalf("unbalanced braces should stay literal, got %v", got)
+	}
+	if got := expandBraces("file{1}/{a,b}.go"); !reflect.DeepEqual(got, []string{"file{1}/a.go", "file{1}/b.go"}) {
+		t.Fatalf("braces without a comma should stay literal, got %v", got)
+	}
+}
+
+func TestFileResolverTreatsBracesWithoutCommaLiterally(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	for _, name := range []string{"file{1}.txt", "file1.txt", "[draft].md", "d.md"} {
+		memory.WriteFile(name, []byte("x"))
+	}
+	resolver := FileResolver{FS: memory}
+
+	for input, want := range map[string][]string{
+		"file{1}.txt":    {"file{1}.txt"},
+		"file{1,2}.txt":  {"file1.txt"},
+		"[draft].md":     {"[draft].md"},
+		"[d].md":         {"d.md"},
+		"file{1}{,}.txt": {"file{1}.txt"},
+	} {
+		got, err := resolver.Resolve(input, false)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got=%v err=%v, want %v", input, got, err, want)
+		}
+	}
+	if isPattern("file{1}.txt") || !isPattern("file{1,2}.txt") {
+		t.Fatal("only braces with a comma should count as a pattern")
+	}
+}
+
+func TestFileResolverResolvesRecursiveGlobs(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	for _, name := range []string{"src/a.ts", "src/app/b.tsx", "src/app/c.js", "lib/d.ts"} {
+		memory.WriteFile(name, []byte("x"))
+	}
+	resolver := FileResolver{FS: memory, Exclude: []string{"src/app/**/*.js"}}
+
+	got, err := resolver.Resolve("src/**/*.{ts,tsx}", false)
+	if err != nil || !reflect.DeepEqual(got, []string{"src/a.ts", "src/app/b.tsx"}) {
+		t.Fatalf("doublestar: got=%#v err=%v", got, err)
+	}
+	got, err = resolver.Resolve("src/*.ts", false)
+	if err != nil || !reflect.DeepEqual(got, []string{"src/a.ts"}) {
+		t.Fatalf("single level: got=%#v err=%v", got, err)
+	}
+	got, err = resolver.Resolve("src/*", true)
+	if err != nil || !reflect.DeepEqual(got, []string{"src/a.ts", "src/app/b.tsx"}) {
+		t.Fatalf("recursive glob: got=%#v err=%v", got, err)
+	}
+	if got, err := resolver.Resolve("missing/**/*.ts", false); err != nil || len(got) != 0 {
+		t.Fatalf("missing base: got=%#v err=%v", got, err)
+	}
+}
@@ -2,37 +2,167 @@ package input
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
 )
 
 type Resolver interface {
 	Resolve(input string, recursive bool) ([]string, error)
 }
 
+type RootResolver interface {
+	ResolveRoot(root config.Root) ([]string, error)
+}
+
 type StdinReader interface {
-	ReadFiles(reader io.Reader) []string
+	ReadFiles(reader io.Reader) ([]string, error)
+}
+
+type StdinStreamer interface {
+	Stream(reader io.Reader, update func(path string, remove bool)) error
+}
+
+type FileResolver struct {
+	Include        []string
+	Exclude        []string
+	FS             fsys.FS
+	FollowSymlinks bool
 }
 
-type FileResolver struct{}
+type Listing struct {
+	Files    []string
+	Excluded []Exclusion
+}
+
+type Exclusion struct {
+	Path string `json:"path"`
+	Rule string `json:"rule"`
+}
+
+type LineStdinReader struct {
+	Null bool
+}
+
+func (r FileResolver) Resolve(value string, recursive bool) ([]string, error) {
+	listing, err := r.List(value, recursive)
+	if err != nil {
+		return nil, err
+	}
+	return listing.Files, nil
+}
+
+func (r FileResolver) ResolveRoot(root config.Root) ([]string, error) {
+	listing, err := r.ListRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	return listing.Files, nil
+}
+
+func (r FileResolver) ListRoot(root config.Root) (Listing, error) {
+	return r.ForRoot(root).List(root.Path, root.Recursive)
+}
+
+func (r FileResolver) ForRoot(root config.Root) FileResolver {
+	r.Include, r.Exclude = root.Include, root.Exclude
+	return r
+}
+
+func ResolveRoot(resolver Resolver, root config.Root) ([]string, error) {
+	if rootResolver, ok := resolver.(RootResolver); ok {
+		return rootResolver.ResolveRoot(root)
+	}
+	return resolver.Resolve(root.Path, root.Recursive)
+}
+
+func ResolveRoots(resolver Resolver, roots []config.Root) ([]string, error) {
+	seen := make(map[string]bool)
+	files := make([]string, 0)
+	for _, root := range roots {
+		resolved, err := ResolveRoot(resolver, root)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range resolved {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (r FileResolver) List(value string, recursive bool) (Listing, error) {
+	candidates, err := discover(fsys.Or(r.FS), value, recursive, r.FollowSymlinks)
+	if err != nil {
+		return Listing{}, err
+	}
+	return r.Filter(candidates), nil
+}
+
+func (r FileResolver) Filter(candidates []string) Listing {
+	listing := Listing{Files: make([]string, 0, len(candidates))}
+	for _, path := range candidates {
+		if rule := r.exclusionRule(path); rule != "" {
+			listing.Excluded = append(listing.Excluded, Exclusion{Path: path, Rule: rule})
+			continue
+		}
+		listing.Files = append(listing.Files, path)
+	}
+	return listing
+}
+
+func (r FileResolver) exclusionRule(path string) string {
+	for _, pattern := range r.Exclude {
+		if MatchPattern(pattern, path) {
+			return "--exclude " + pattern
+		}
+	}
+	if len(r.Include) == 0 {
+		return ""
+	}
+	for _, pattern := range r.Include {
+		if MatchPattern(pattern, path) {
+			return ""
+		}
+	}
+	return "not matched by --include " + strings.Join(r.Include, ",")
+}
+
+func MatchPattern(pattern, path string) bool {
+	if !strings.Contains(filepath.ToSlash(pattern), "/") {
+		if matched, _ := match(pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+	matched, _ := match(pattern, path)
+	return matched
+}
 
-type LineStdinReader struct{}
+func exists(fileSystem fsys.FS, value string) bool {
+	_, err := fileSystem.Stat(value)
+	return err == nil
+}
 
-func (FileResolver) Resolve(value string, recursive bool) ([]string, error) {
-	if strings.ContainsAny(value, "*?[]") {
-		matches, err := filepath.Glob(value)
+func discover(fileSystem fsys.FS, value string, recursive, follow bool) ([]string, error) {
+	if isPattern(value) && !exists(fileSystem, value) {
+		matches, err := glob(fileSystem, value, recursive, follow)
 		if err != nil {
 			return nil, fmt.Errorf("process glob pattern: %w", err)
 		}
-		sort.Strings(matches)
 		return matches, nil
 	}
 
-	info, err := os.Stat(value)
+	info, err := fileSystem.Stat(value)
 	if err != nil {
 		return nil, fmt.Errorf("access input %s: %w", value, err)
 	}
@@ -42,54 +172,169 @@ func (FileResolver) Resolve(value string, recursive bool) ([]string, error) {
 	}
 
 	if recursive {
-		return walkFiles(value)
+		return walkFiles(fileSystem, value, follow)
 	}
 
-	return listTopLevelFiles(value)
+	return listTopLevelFiles(fileSystem, value, follow)
 }
 
-func (LineStdinReader) ReadFiles(reader io.Reader) []string {
+func (r LineStdinReader) ReadFiles(reader io.Reader) ([]string, error) {
 	files := make([]string, 0)
-	scanner := bufio.NewScanner(reader)
+	scanner := r.scanner(reader)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			files = append(files, line)
+		if entry := r.entry(scanner.Text()); entry != "" {
+			files = append(files, entry)
 		}
 	}
-	return files
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read paths from stdin: %w", err)
+	}
+	return files, nil
 }
 
-func walkFiles(root string) ([]string, error) {
-	files := make([]string, 0)
-	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+func (r LineStdinReader) Stream(reader io.Reader, update func(path string, remove bool)) error {
+	scanner := r.scanner(reader)
+	for scanner.Scan() {
+		entry := r.entry(scanner.Text())
+		if path, removed := strings.CutPrefix(entry, "-"); removed && path != "" {
+			update(filepath.Clean(path), true)
+		} else if entry != "" {
+			update(filepath.Clean(entry), false)
+		}
+	}
+	return scanner.Err()
+}
+
+func (r LineStdinReader) scanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if r.Null {
+		scanner.Split(splitNull)
+	}
+	return scanner
+}
+
+func (r LineStdinReader) entry(record string) string {
+	if r.Null {
+		return record
+	}
+	return strings.TrimSuffix(record, "\r")
+}
+
+func splitNull(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func walkFiles(fileSystem fsys.FS, root string, follow bool) ([]string, error) {
+	walker := newSymlinkWalker(fileSystem, follow)
+	if err := walker.walk(root); err != nil {
+		return nil, fmt.Errorf("walk directory %s: %w", root, err)
+	}
+	sort.Strings(walker.files)
+	return walker.files, nil
+}
+
+func listTopLevelFiles(fileSystem fsys.FS, directory string, follow bool) ([]string, error) {
+	entries, err := fileSystem.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("read directory %s: %w", directory, err)
+	}
+
+	walker := newSymlinkWalker(fileSystem, follow)
+	for _, entry := range regularFirst(entries) {
+		path := filepath.Join(directory, entry.Name())
+		if isDir, ok := walker.isDir(path, entry); ok && !isDir {
+			walker.add(path)
+		}
+	}
+	sort.Strings(walker.files)
+	return walker.files, nil
+}
+
+type symlinkWalker struct {
+	fs      fsys.FS
+	follow  bool
+	visited map[string]bool
+	targets map[string]bool
+	files   []string
+}
+
+func newSymlinkWalker(fileSystem fsys.FS, follow bool) *symlinkWalker {
+	return &symlinkWalker{
+		fs:      fileSystem,
+		follow:  follow,
+		visited: make(map[string]bool),
+		targets: make(map[string]bool),
+		files:   make([]string, 0),
+	}
+}
+
+func (w *symlinkWalker) walk(root string) error {
+	queue := []string{root}
+	for len(queue) > 0 {
+		directory := queue[0]
+		queue = queue[1:]
+		if w.follow {
+			real, err := fsys.EvalSymlinks(w.fs, directory)
+			if err != nil {
+				return err
+			}
+			if w.visited[real] {
+				continue
+			}
+			w.visited[real] = true
+		}
+
+		entries, err := w.fs.ReadDir(directory)
 		if err != nil {
 			return err
 		}
-		if !entry.IsDir() {
-			files = append(files, path)
+		for _, entry := range regularFirst(entries) {
+			path := filepath.Join(directory, entry.Name())
+			isDir, ok := w.isDir(path, entry)
+			switch {
+			case !ok:
+			case !isDir:
+				w.add(path)
+			case entry.IsDir() || w.follow:
+				queue = append(queue, path)
+			}
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, fmt.Errorf("walk directory %s: %w", root, err)
 	}
-	sort.Strings(files)
-	return files, nil
+	return nil
 }
 
-func listTopLevelFiles(directory string) ([]string, error) {
-	entries, err := os.ReadDir(directory)
+func (w *symlinkWalker) isDir(path string, entry fs.DirEntry) (bool, bool) {
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return entry.IsDir(), true
+	}
+	info, err := w.fs.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("read directory %s: %w", directory, err)
+		return false, false
 	}
+	return info.IsDir(), true
+}
 
-	files := make([]string, 0)
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			files = append(files, filepath.Join(directory, entry.Name()))
+func (w *symlinkWalker) add(path string) {
+	if w.follow {
+		real, err := fsys.EvalSymlinks(w.fs, path)
+		if err != nil || w.targets[real] {
+			return
 		}
+		w.targets[real] = true
 	}
-	sort.Strings(files)
-	return files, nil
+	w.files = append(w.files, path)
+}
+
+func regularFirst(entries []fs.DirEntry) []fs.DirEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Type()&fs.ModeSymlink == 0 && entries[j].Type()&fs.ModeSymlink != 0
+	})
+	return entries
 }
@@ -1,18 +1,62 @@
 package input
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
 )
 
-func TestLineStdinReaderTrimsBlankLines(t *testing.T) {
-	got := LineStdinReader{}.ReadFiles(strings.NewReader(" a.go \n\n\tb.go\n"))
-	want := []string{"a.go", "b.go"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("expected %#v, got %#v", want, got)
+func TestLineStdinReaderSkipsBlankLinesAndKeepsSpaces(t *testing.T) {
+	got, err := LineStdinReader{}.ReadFiles(strings.NewReader(" a.go \n\n\tb.go\r\n"))
+	want := []string{" a.go ", "\tb.go"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v (err %v)", want, got, err)
+	}
+}
+
+func TestLineStdinReaderReadsNullDelimitedNames(t *testing.T) {
+	got, err := LineStdinReader{Null: true}.ReadFiles(strings.NewReader("a.go\x00two\nlines.txt\x00\x00 spaced \x00last"))
+	want := []string{"a.go", "two\nlines.txt", " spaced ", "last"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v (err %v)", want, got, err)
+	}
+}
+
+func TestLineStdinReaderReportsOversizedRecords(t *testing.T) {
+	long := strings.Repeat("a", 2*1024*1024)
+	if files, err := (LineStdinReader{}).ReadFiles(strings.NewReader("a.go\n" + long + "\nb.go\n")); err == nil {
+		t.Fatalf("expected an error for a record over the size limit, got %d files", len(files))
+	}
+}
+
+func TestLineStdinReaderStreamsAdditionsAndRemovals(t *testing.T) {
+	updates := make([]string, 0)
+	err := LineStdinReader{}.Stream(strings.NewReader("a.go\nb.go\n-a.go\n-\n./-x.go\n--x.go\n\n"), func(path string, remove bool) {
+		if remove {
+			path = "remove " + path
+		}
+		updates = append(updates, path)
+	})
+	want := []string{"a.go", "b.go", "remove a.go", "-", "-x.go", "remove -x.go"}
+	if err != nil || !reflect.DeepEqual(updates, want) {
+		t.Fatalf("updates=%#v err=%v", updates, err)
+	}
+}
+
+func TestLineStdinReaderStreamsEscapedDashNamesInNullMode(t *testing.T) {
+	updates := make([]string, 0)
+	err := LineStdinReader{Null: true}.Stream(strings.NewReader("./-v\x00-./-v\x00"), func(path string, remove bool) {
+		updates = append(updates, fmt.Sprintf("%s %t", path, remove))
+	})
+	want := []string{"-v false", "-v true"}
+	if err != nil || !reflect.DeepEqual(updates, want) {
+		t.Fatalf("updates=%#v err=%v", updates, err)
 	}
 }
 
@@ -54,3 +98,77 @@ func TestFileResolverResolvesFileDirectoryAndGlob(t *testing.T) {
 		t.Fatalf("glob: got=%#v err=%v", got, err)
 	}
 }
+
+func TestFileResolverListReportsExclusions(t *testing.T) {
+	tmp := "project"
+	code := filepath.Join(tmp, "a.go")
+	test := filepath.Join(tmp, "a_test.go")
+	notes := filepath.Join(tmp, "notes.md")
+	memory := fsys.NewMemory(nil)
+	for _, path := range []string{code, test, notes} {
+		memory.WriteFile(path, []byte("x"))
+	}
+
+	resolver := FileResolver{Include: []string{"*.go"}, Exclude: []string{"*_test.go"}, FS: memory}
+	listing, err := resolver.List(tmp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listing.Files, []string{code}) {
+		t.Fatalf("unexpected files: %#v", listing.Files)
+	}
+	want := []Exclusion{
+		{Path: test, Rule: "--exclude *_test.go"},
+		{Path: notes, Rule: "not matched by --include *.go"},
+	}
+	if !reflect.DeepEqual(listing.Excluded, want) {
+		t.Fatalf("expected %#v, got %#v", want, listing.Excluded)
+	}
+
+	files, err := resolver.Resolve(tmp, false)
+	if err != nil || !reflect.DeepEqual(files, []string{code}) {
+		t.Fatalf("resolve: got=%#v err=%v", files, err)
+	}
+}
+
+func TestFileResolverSymlinkPolicy(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	memory.WriteFile("repo/main.go", []byte("x"))
+	memory.WriteFile("shared/lib/util.go", []byte("x"))
+	memory.Symlink("../shared/lib", "repo/vendor")
+	memory.Symlink("../shared/lib", "repo/third_party")
+	memory.Symlink("..", "repo/loop")
+	memory.Symlink("main.go", "repo/alias.go")
+	memory.Symlink("missing.go", "repo/broken.go")
+
+	got, err := FileResolver{FS: memory}.Resolve("repo", true)
+	want := []string{"repo/alias.go", "repo/main.go"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("without following: got=%#v err=%v", got, err)
+	}
+
+	got, err = FileResolver{FS: memory, FollowSymlinks: true}.Resolve("repo", true)
+	want = []string{"repo/main.go", "repo/third_party/util.go"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("following: got=%#v err=%v", got, err)
+	}
+}
+
+func TestResolveRootsAppliesPerRootFilters(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	for _, path := range []string{"cmd/main.go", "cmd/tool/tool.go", "internal/a.go", "internal/a_test.go", "config.yaml", "README.md"} {
+		memory.WriteFile(path, []byte("x"))
+	}
+
+	roots := []config.Root{
+		{Path: "cmd", Recursive: true},
+		{Path: "internal", Exclude: []string{"*_test.go"}},
+		{Path: "config.yaml"},
+		{Path: "cmd/main.go"},
+	}
+	got, err := ResolveRoots(FileResolver{Include: []string{"*.md"}, FS: memory}, roots)
+	want := []string{"cmd/main.go", "cmd/tool/tool.go", "internal/a.go", "config.yaml"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got=%#v err=%v", got, err)
+	}
+}
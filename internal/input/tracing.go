@@ -0,0 +1,46 @@
+package input
+
+import (
+	"strings"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/logging"
+)
+
+const tracedFiles = 10
+
+type TracingResolver struct {
+	Next Resolver
+	Log  *logging.Logger
+}
+
+func (r TracingResolver) Resolve(value string, recursive bool) ([]string, error) {
+	files, err := r.Next.Resolve(value, recursive)
+	r.trace(config.Root{Path: value, Recursive: recursive}, files, err)
+	return files, err
+}
+
+func (r TracingResolver) ResolveRoot(root config.Root) ([]string, error) {
+	files, err := ResolveRoot(r.Next, root)
+	r.trace(root, files, err)
+	return files, err
+}
+
+func (r TracingResolver) trace(root config.Root, files []string, err error) {
+	if err != nil {
+		r.Log.Debugf("resolve %s (recursive %t, include %v, exclude %v) failed: %v", root.Path, root.Recursive, root.Include, root.Exclude, err)
+		return
+	}
+	if !r.Log.Enabled(logging.LevelDebug) {
+		return
+	}
+	shown := files[:min(len(files), tracedFiles)]
+	more := ""
+	if len(files) > len(shown) {
+		more = ", ..."
+	}
+	r.Log.Debugf(
+		"resolve %s (recursive %t, include %v, exclude %v): %d files [%s%s]",
+		root.Path, root.Recursive, root.Include, root.Exclude, len(files), strings.Join(shown, ", "), more,
+	)
+}
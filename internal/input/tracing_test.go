@@ -0,0 +1,39 @@
+package input
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/logging"
+)
+
+func TestTracingResolverLogsResults(t *testing.T) {
+	memory := fsys.NewMemory(nil)
+	for i := range 12 {
+		memory.WriteFile("src/"+string(rune('a'+i))+".go", nil)
+	}
+	var output bytes.Buffer
+	resolver := TracingResolver{Next: FileResolver{FS: memory}, Log: logging.New(&output, logging.LevelDebug)}
+
+	files, err := resolver.ResolveRoot(config.Root{Path: "src", Exclude: []string{"l.go"}})
+	if err != nil || len(files) != 11 {
+		t.Fatalf("files=%v err=%v", files, err)
+	}
+	if _, err := resolver.Resolve("missing", false); err == nil {
+		t.Fatal("expected an error for a missing input")
+	}
+
+	text := output.String()
+	for _, expected := range []string{
+		"resolve src (recursive false, include [], exclude [l.go]): 11 files [src/a.go, src/b.go,",
+		"src/j.go, ...]",
+		"resolve missing (recursive false, include [], exclude []) failed:",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, text)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package listing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tiendu/gentr/internal/cli"
+	"github.com/tiendu/gentr/internal/config"
+	inputpkg "github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/terminal"
+)
+
+type Lister interface {
+	List(input string, recursive bool) (inputpkg.Listing, error)
+}
+
+type Command struct {
+	NewLister func(opts config.Options) Lister
+	Stdout    io.Writer
+	Stderr    io.Writer
+}
+
+type Report struct {
+	Files       []File               `json:"files"`
+	Extensions  map[string]int       `json:"extensions"`
+	Directories map[string]int       `json:"directories"`
+	TotalSize   int64                `json:"total_size"`
+	Excluded    []inputpkg.Exclusion `json:"excluded"`
+}
+
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+func (c Command) Run(args []string) int {
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	flags, options := cli.NewFlagSet("gentr ls")
+	jsonOutput := flags.Bool("json", false, "Print the listing as JSON")
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	opts := options()
+
+	newLister := c.NewLister
+	if newLister == nil {
+		newLister = func(opts config.Options) Lister {
+			return inputpkg.FileResolver{Include: opts.Include, Exclude: opts.Exclude, FollowSymlinks: opts.FollowSymlinks}
+		}
+	}
+	listing, err := listRoots(newLister, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	report := Build(listing)
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+	writeText(stdout, report)
+	return 0
+}
+
+func listRoots(newLister func(opts config.Options) Lister, opts config.Options) (inputpkg.Listing, error) {
+	merged := inputpkg.Listing{}
+	seen := make(map[string]bool)
+	for _, root := range opts.InputRoots() {
+		rootOpts := opts
+		rootOpts.Include, rootOpts.Exclude = root.Include, root.Exclude
+		listing, err := newLister(rootOpts).List(root.Path, root.Recursive)
+		if err != nil {
+			return inputpkg.Listing{}, err
+		}
+		for _, file := range listing.Files {
+			if !seen[file] {
+				seen[file] = true
+				merged.Files = append(merged.Files, file)
+			}
+		}
+		merged.Excluded = append(merged.Excluded, listing.Excluded...)
+	}
+	return merged, nil
+}
+
+func Build(listing inputpkg.Listing) Report {
+	report := Report{
+		Files:       make([]File, 0, len(listing.Files)),
+		Extensions:  make(map[string]int),
+		Directories: make(map[string]int),
+		Excluded:    listing.Excluded,
+	}
+	if report.Excluded == nil {
+		report.Excluded = []inputpkg.Exclusion{}
+	}
+
+	for _, path := range listing.Files {
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		extension := filepath.Ext(path)
+		if extension == "" {
+			extension = "(none)"
+		}
+
+		report.Files = append(report.Files, File{Path: path, Size: size})
+		report.Extensions[extension]++
+		report.Directories[filepath.Dir(path)]++
+		report.TotalSize += size
+	}
+	return report
+}
+
+func writeText(writer io.Writer, report Report) {
+	fmt.Fprintln(writer, terminal.Bold(terminal.Color(fmt.Sprintf("Resolved files (%d):", len(report.Files)), "blue")))
+	for _, file := range report.Files {
+		fmt.Fprintf(writer, "  %s (%s)\n", file.Path, formatSize(file.Size))
+	}
+
+	fmt.Fprintln(writer, terminal.Bold(terminal.Color("By extension:", "blue")))
+	writeCounts(writer, report.Extensions)
+	fmt.Fprintln(writer, terminal.Bold(terminal.Color("By directory:", "blue")))
+	writeCounts(writer, report.Directories)
+	fmt.Fprintf(writer, "%s %s\n", terminal.Bold(terminal.Color("Total size:", "blue")), formatSize(report.TotalSize))
+
+	if len(report.Excluded) == 0 {
+		return
+	}
+	fmt.Fprintln(writer, terminal.Bold(terminal.Color(fmt.Sprintf("Excluded files (%d):", len(report.Excluded)), "blue")))
+	for _, exclusion := range report.Excluded {
+		fmt.Fprintf(writer, "  %s %s\n", exclusion.Path, terminal.Color("("+exclusion.Rule+")", "gray"))
+	}
+}
+
+func writeCounts(writer io.Writer, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(writer, "  %-24s %d\n", key, counts[key])
+	}
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, suffix := float64(size), "B"
+	for _, next := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		if value < unit {
+			break
+		}
+		value /= unit
+		suffix = next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
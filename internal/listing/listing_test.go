@@ -0,0 +1,140 @@
+package listing
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	inputpkg "github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/terminal"
+)
+
+type stubLister struct {
+	listing   inputpkg.Listing
+	input     string
+	recursive bool
+}
+
+func (l *stubLister) List(input string, recursive bool) (inputpkg.Listing, error) {
+	l.input, l.recursive = input, recursive
+	return l.listing, nil
+}
+
+func TestBuildCountsFilesBySizeExtensionAndDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	goFile := writeFile(t, filepath.Join(tmp, "a.go"), "package a")
+	readme := writeFile(t, filepath.Join(tmp, "README"), "hi")
+
+	report := Build(inputpkg.Listing{Files: []string{goFile, readme}})
+	if len(report.Files) != 2 || report.TotalSize != 11 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Extensions[".go"] != 1 || report.Extensions["(none)"] != 1 || report.Directories[tmp] != 2 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+}
+
+func TestCommandPrintsTextListing(t *testing.T) {
+	lister := &stubLister{listing: inputpkg.Listing{
+		Files:    []string{"missing.go"},
+		Excluded: []inputpkg.Exclusion{{Path: "a_test.go", Rule: "--exclude *_test.go"}},
+	}}
+	var stdout bytes.Buffer
+	command := Command{
+		NewLister: func(opts config.Options) Lister {
+			if len(opts.Exclude) != 1 || opts.Exclude[0] != "*_test.go" {
+				t.Fatalf("unexpected options: %+v", opts)
+			}
+			return lister
+		},
+		Stdout: &stdout,
+	}
+
+	if code := command.Run([]string{"-i", "./src", "-r", "--exclude", "*_test.go"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if lister.input != "./src" || !lister.recursive {
+		t.Fatalf("unexpected lister call: %+v", lister)
+	}
+	text := terminal.StripANSI(stdout.String())
+	for _, expected := range []string{"Resolved files (1):", "missing.go (0 B)", ".go", "Excluded files (1):", "a_test.go (--exclude *_test.go)"} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, text)
+		}
+	}
+}
+
+func TestCommandPrintsJSON(t *testing.T) {
+	lister := &stubLister{listing: inputpkg.Listing{Files: []string{"a.go"}}}
+	var stdout bytes.Buffer
+	command := Command{NewLister: func(config.Options) Lister { return lister }, Stdout: &stdout}
+
+	if code := command.Run([]string{"--json"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	var report Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout.String(), err)
+	}
+	if len(report.Files) != 1 || report.Files[0].Path != "a.go" || report.Excluded == nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestCommandMergesEveryInputRoot(t *testing.T) {
+	listings := map[string]inputpkg.Listing{
+		"cmd":         {Files: []string{"cmd/main.go", "shared.go"}},
+		"config.yaml": {Files: []string{"shared.go"}, Excluded: []inputpkg.Exclusion{{Path: "config.yaml", Rule: "--exclude *.yaml"}}},
+	}
+	filters := make(map[string]string)
+	var stdout bytes.Buffer
+	command := Command{
+		NewLister: func(opts config.Options) Lister {
+			return listerFunc(func(input string, recursive bool) (inputpkg.Listing, error) {
+				filters[input] = strings.Join(opts.Exclude, ",")
+				return listings[input], nil
+			})
+		},
+		Stdout: &stdout,
+	}
+
+	if code := command.Run([]string{"--json", "-i", "cmd", "-r", "-i", "config.yaml", "--exclude", "*.yaml"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	var report Report
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout.String(), err)
+	}
+	if len(report.Files) != 2 || len(report.Excluded) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if filters["cmd"] != "" || filters["config.yaml"] != "*.yaml" {
+		t.Fatalf("filters were not applied per root: %v", filters)
+	}
+}
+
+type listerFunc func(input string, recursive bool) (inputpkg.Listing, error)
+
+func (f listerFunc) List(input string, recursive bool) (inputpkg.Listing, error) {
+	return f(input, recursive)
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 3 << 20: "3.0 MiB"} {
+		if got := formatSize(size); got != want {
+			t.Fatalf("size %d: expected %q, got %q", size, want, got)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,173 @@
+package livereload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/tiendu/gentr/pkg/gentr"
+)
+
+const clientScript = `(function () {
+  var source = new EventSource((document.currentScript && new URL(document.currentScript.src).origin || "") + "/livereload");
+  source.addEventListener("finished", function (message) {
+    var data = JSON.parse(message.data);
+    if (data.exit_code !== 0) {
+      return;
+    }
+    if (!data.css_only) {
+      window.location.reload();
+      return;
+    }
+    document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+      var url = new URL(link.href);
+      url.searchParams.set("livereload", Date.now());
+      link.href = url.toString();
+    });
+  });
+})();
+`
+
+type Message struct {
+	Event string
+	Data  any
+}
+
+type ChangeData struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+type FinishedData struct {
+	Path     string   `json:"path"`
+	ExitCode int      `json:"exit_code"`
+	Changed  []string `json:"changed"`
+	CSSOnly  bool     `json:"css_only"`
+}
+
+type Server struct {
+	mutex   sync.Mutex
+	clients map[chan Message]struct{}
+	changed []string
+}
+
+func New() *Server {
+	return &Server{clients: make(map[chan Message]struct{})}
+}
+
+func (s *Server) Handler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livereload.js", func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(writer, clientScript)
+	})
+	mux.HandleFunc("GET /livereload", s.serveEvents)
+	return mux
+}
+
+func (s *Server) Consume(ctx context.Context, events <-chan gentr.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			s.Handle(event)
+		}
+	}
+}
+
+func (s *Server) Handle(event gentr.Event) {
+	if event.Kind != gentr.EventRun {
+		s.mutex.Lock()
+		s.changed = append(s.changed, event.Path)
+		s.mutex.Unlock()
+		s.Broadcast(Message{Event: "change", Data: ChangeData{Kind: event.Kind, Path: event.Path}})
+		return
+	}
+
+	s.mutex.Lock()
+	changed := s.changed
+	s.changed = nil
+	s.mutex.Unlock()
+
+	exitCode := 0
+	if event.ExitCode != nil {
+		exitCode = *event.ExitCode
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	s.Broadcast(Message{Event: "finished", Data: FinishedData{
+		Path:     event.Path,
+		ExitCode: exitCode,
+		Changed:  changed,
+		CSSOnly:  cssOnly(changed),
+	}})
+}
+
+func (s *Server) Broadcast(message Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- message:
+		default:
+		}
+	}
+}
+
+func (s *Server) serveEvents(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan Message, 16)
+	s.mutex.Lock()
+	s.clients[client] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, client)
+		s.mutex.Unlock()
+	}()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Access-Control-Allow-Origin", "*")
+	fmt.Fprint(writer, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case message := <-client:
+			data, err := json.Marshal(message.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", message.Event, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func cssOnly(paths []string) bool {
+	if len(paths) == 0 {
+		return false
+	}
+	for _, path := range paths {
+		if filepath.Ext(path) != ".css" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package livereload
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/pkg/gentr"
+)
+
+func TestServerStreamsChangeAndFinishedEvents(t *testing.T) {
+	server := New()
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/livereload", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", response.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(response.Body)
+	if name, _ := readEvent(t, reader); name != "" {
+		t.Fatalf("unexpected greeting event: %q", name)
+	}
+
+	exitCode := 0
+	server.Handle(gentr.Event{Kind: gentr.EventChanged, Path: "site/style.css"})
+	server.Handle(gentr.Event{Kind: gentr.EventRun, Path: "site/style.css", ExitCode: &exitCode})
+
+	name, data := readEvent(t, reader)
+	if name != "change" || !strings.Contains(data, `"path":"site/style.css"`) {
+		t.Fatalf("unexpected change event: %s %s", name, data)
+	}
+	name, data = readEvent(t, reader)
+	var finished FinishedData
+	if err := json.Unmarshal([]byte(data), &finished); err != nil || name != "finished" {
+		t.Fatalf("unexpected finished event: %s %s (%v)", name, data, err)
+	}
+	if finished.ExitCode != 0 || !finished.CSSOnly || len(finished.Changed) != 1 {
+		t.Fatalf("unexpected finished data: %+v", finished)
+	}
+}
+
+func TestServerServesClientScript(t *testing.T) {
+	httpServer := httptest.NewServer(New().Handler())
+	defer httpServer.Close()
+
+	response, err := http.Get(httpServer.URL + "/livereload.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "EventSource") || !strings.Contains(string(body), "finished") {
+		t.Fatalf("unexpected client script: %s", body)
+	}
+}
+
+func TestCSSOnly(t *testing.T) {
+	if !cssOnly([]string{"a.css", "b/c.css"}) || cssOnly([]string{"a.css", "index.html"}) || cssOnly(nil) {
+		t.Fatal("unexpected css detection")
+	}
+}
+
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
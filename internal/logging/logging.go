@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+type Logger struct {
+	mutex  *sync.Mutex
+	writer io.Writer
+	level  Level
+	scope  string
+	now    func() time.Time
+}
+
+func New(writer io.Writer, level Level) *Logger {
+	if writer == nil {
+		writer = io.Discard
+	}
+	return &Logger{mutex: &sync.Mutex{}, writer: writer, level: level, now: time.Now}
+}
+
+func (l *Logger) Named(scope string) *Logger {
+	if l == nil {
+		return nil
+	}
+	named := *l
+	if named.scope != "" {
+		scope = named.scope + "." + scope
+	}
+	named.scope = scope
+	return &named
+}
+
+func (l *Logger) SetNow(now func() time.Time) {
+	if l != nil && now != nil {
+		l.now = now
+	}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.level
+}
+
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf(LevelDebug, format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...any) {
+	l.logf(LevelInfo, format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...any) {
+	l.logf(LevelWarn, format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...any) {
+	l.logf(LevelError, format, args...)
+}
+
+func (l *Logger) logf(level Level, format string, args ...any) {
+	if !l.Enabled(level) {
+		return
+	}
+	var line strings.Builder
+	line.WriteString(l.now().Format("2006-01-02T15:04:05.000Z07:00"))
+	fmt.Fprintf(&line, " %-5s ", level)
+	if l.scope != "" {
+		line.WriteString(l.scope + ": ")
+	}
+	fmt.Fprintf(&line, format, args...)
+	line.WriteByte('\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	io.WriteString(l.writer, line.String())
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerFiltersByLevelAndFormatsLines(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(&output, LevelInfo)
+	logger.SetNow(func() time.Time { return time.Date(2026, 1, 1, 8, 30, 0, 0, time.UTC) })
+
+	logger.Debugf("hidden %d", 1)
+	logger.Named("watch").Infof("tracking %d files", 3)
+	logger.Named("watch").Named("poll").Warnf("slow")
+	logger.Errorf("failed: %v", "boom")
+
+	want := "2026-01-01T08:30:00.000Z INFO  watch: tracking 3 files\n" +
+		"2026-01-01T08:30:00.000Z WARN  watch.poll: slow\n" +
+		"2026-01-01T08:30:00.000Z ERROR failed: boom\n"
+	if output.String() != want {
+		t.Fatalf("unexpected output:\n%s", output.String())
+	}
+	if logger.Enabled(LevelDebug) || !logger.Enabled(LevelError) {
+		t.Fatal("unexpected enabled levels")
+	}
+}
+
+func TestNilLoggerIsNoop(t *testing.T) {
+	var logger *Logger
+	logger.Debugf("x")
+	logger.Named("watch").Errorf("x")
+	logger.SetNow(time.Now)
+	if logger.Enabled(LevelError) {
+		t.Fatal("a nil logger should not be enabled")
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	levels := []string{LevelDebug.String(), LevelInfo.String(), LevelWarn.String(), LevelError.String()}
+	if strings.Join(levels, ",") != "DEBUG,INFO,WARN,ERROR" {
+		t.Fatalf("unexpected level names: %v", levels)
+	}
+}
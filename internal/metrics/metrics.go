@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+func (g *Gauge) Set(value float64) {
+	g.bits.Store(math.Float64bits(value))
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+type Histogram struct {
+	mutex   sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for index, bound := range h.buckets {
+		if value <= bound {
+			h.counts[index]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) Count() uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.count
+}
+
+type Set struct {
+	ChangesDetected Counter
+	Runs            Counter
+	Failures        Counter
+	Deletions       Counter
+	PollOverruns    Counter
+	CommandDuration *Histogram
+	PollDuration    *Histogram
+	RescanDuration  *Histogram
+	TrackedFiles    Gauge
+	CacheBytes      Gauge
+}
+
+func New() *Set {
+	return &Set{
+		CommandDuration: NewHistogram([]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}),
+		PollDuration:    NewHistogram([]float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}),
+		RescanDuration:  NewHistogram([]float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}),
+	}
+}
+
+func (s *Set) ChangeDetected() {
+	if s != nil {
+		s.ChangesDetected.Inc()
+	}
+}
+
+func (s *Set) Deleted() {
+	if s != nil {
+		s.Deletions.Inc()
+	}
+}
+
+func (s *Set) RunFinished(duration time.Duration, exitCode int) {
+	if s == nil {
+		return
+	}
+	s.Runs.Inc()
+	if exitCode != 0 {
+		s.Failures.Inc()
+	}
+	s.CommandDuration.Observe(duration.Seconds())
+}
+
+func (s *Set) PollFinished(duration time.Duration) {
+	if s != nil {
+		s.PollDuration.Observe(duration.Seconds())
+	}
+}
+
+func (s *Set) PollOverrun() {
+	if s != nil {
+		s.PollOverruns.Inc()
+	}
+}
+
+func (s *Set) RescanFinished(duration time.Duration) {
+	if s != nil {
+		s.RescanDuration.Observe(duration.Seconds())
+	}
+}
+
+func (s *Set) SetTrackedFiles(count int) {
+	if s != nil {
+		s.TrackedFiles.Set(float64(count))
+	}
+}
+
+func (s *Set) SetCacheBytes(bytes int) {
+	if s != nil {
+		s.CacheBytes.Set(float64(bytes))
+	}
+}
+
+func (s *Set) WriteText(writer io.Writer) {
+	writeCounter(writer, "gentr_changes_detected_total", "File changes detected.", &s.ChangesDetected)
+	writeCounter(writer, "gentr_runs_total", "Commands run.", &s.Runs)
+	writeCounter(writer, "gentr_run_failures_total", "Commands that exited with a non-zero status.", &s.Failures)
+	writeCounter(writer, "gentr_deletions_total", "Watched files deleted.", &s.Deletions)
+	writeCounter(writer, "gentr_poll_overruns_total", "Polls that took longer than the poll interval.", &s.PollOverruns)
+	writeHistogram(writer, "gentr_command_duration_seconds", "Command run duration.", s.CommandDuration)
+	writeHistogram(writer, "gentr_poll_duration_seconds", "Time spent polling watched files.", s.PollDuration)
+	writeHistogram(writer, "gentr_rescan_duration_seconds", "Time spent rescanning the input for new files.", s.RescanDuration)
+	writeGauge(writer, "gentr_tracked_files", "Files currently watched.", &s.TrackedFiles)
+	writeGauge(writer, "gentr_content_cache_bytes", "Bytes of file content cached for diffs.", &s.CacheBytes)
+}
+
+func (s *Set) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		s.WriteText(writer)
+	})
+}
+
+func writeCounter(writer io.Writer, name, help string, counter *Counter) {
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, counter.Value())
+}
+
+func writeGauge(writer io.Writer, name, help string, gauge *Gauge) {
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(gauge.Value()))
+}
+
+func writeHistogram(writer io.Writer, name, help string, histogram *Histogram) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for index, bound := range histogram.buckets {
+		fmt.Fprintf(writer, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), histogram.counts[index])
+	}
+	fmt.Fprintf(writer, "%s_bucket{le=\"+Inf\"} %d\n", name, histogram.count)
+	fmt.Fprintf(writer, "%s_sum %s\n%s_count %d\n", name, formatFloat(histogram.sum), name, histogram.count)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetWritesPrometheusText(t *testing.T) {
+	set := New()
+	set.ChangeDetected()
+	set.Deleted()
+	set.RunFinished(200*time.Millisecond, 0)
+	set.RunFinished(3*time.Second, 2)
+	set.PollFinished(2 * time.Millisecond)
+	set.PollOverrun()
+	set.SetTrackedFiles(12)
+	set.SetCacheBytes(2048)
+
+	var output bytes.Buffer
+	set.WriteText(&output)
+	text := output.String()
+	for _, expected := range []string{
+		"# TYPE gentr_changes_detected_total counter\ngentr_changes_detected_total 1\n",
+		"gentr_runs_total 2\n",
+		"gentr_run_failures_total 1\n",
+		"gentr_deletions_total 1\n",
+		"# TYPE gentr_command_duration_seconds histogram\n",
+		`gentr_command_duration_seconds_bucket{le="0.25"} 1`,
+		`gentr_command_duration_seconds_bucket{le="5"} 2`,
+		`gentr_command_duration_seconds_bucket{le="+Inf"} 2`,
+		"gentr_command_duration_seconds_sum 3.2\n",
+		"gentr_poll_duration_seconds_count 1\n",
+		"gentr_poll_overruns_total 1\n",
+		"gentr_rescan_duration_seconds_count 0\n",
+		"gentr_tracked_files 12\n",
+		"gentr_content_cache_bytes 2048\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, text)
+		}
+	}
+}
+
+func TestNilSetIsNoop(t *testing.T) {
+	var set *Set
+	set.ChangeDetected()
+	set.Deleted()
+	set.RunFinished(time.Second, 1)
+	set.PollFinished(time.Second)
+	set.PollOverrun()
+	set.RescanFinished(time.Second)
+	set.SetTrackedFiles(1)
+	set.SetCacheBytes(1)
+}
+
+func TestHandlerServesTextFormat(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	New().Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") || !strings.Contains(string(body), "gentr_runs_total 0") {
+		t.Fatalf("unexpected response: %q %s", recorder.Header().Get("Content-Type"), body)
+	}
+}
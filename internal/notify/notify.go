@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type Sender interface {
+	Send(title, body string, urgent bool) error
+}
+
+type NotifySend struct {
+	Path string
+}
+
+func (s NotifySend) Send(title, body string, urgent bool) error {
+	urgency := "normal"
+	if urgent {
+		urgency = "critical"
+	}
+	return runTool(exec.Command(s.Path, "--app-name", "gentr", "--urgency", urgency, "--", title, body))
+}
+
+type DBus struct {
+	Path string
+}
+
+func (s DBus) Send(title, body string, urgent bool) error {
+	urgency := 1
+	if urgent {
+		urgency = 2
+	}
+	return runTool(exec.Command(
+		s.Path, "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"gentr", "0", "", strconv.Quote(title), strconv.Quote(body),
+		"[]", fmt.Sprintf("{'urgency': <byte %d>}", urgency), "5000",
+	))
+}
+
+func DetectSender() Sender {
+	if path, err := exec.LookPath("notify-send"); err == nil {
+		return NotifySend{Path: path}
+	}
+	if path, err := exec.LookPath("gdbus"); err == nil {
+		return DBus{Path: path}
+	}
+	return nil
+}
+
+type Reporter struct {
+	Sender   Sender
+	Recovery bool
+	Lines    int
+	Warnings io.Writer
+
+	failing  bool
+	disabled bool
+}
+
+func NewReporter(sender Sender, recovery bool, warnings io.Writer) *Reporter {
+	if warnings == nil {
+		warnings = io.Discard
+	}
+	reporter := &Reporter{Sender: sender, Recovery: recovery, Lines: 5, Warnings: warnings}
+	if sender == nil {
+		fmt.Fprintln(warnings, "[!] No desktop notification tool found (notify-send or gdbus), notifications disabled")
+		reporter.disabled = true
+	}
+	return reporter
+}
+
+func (r *Reporter) Report(result runner.Result, _ config.Options) {
+	if result.Hook != "" || result.Step != "" {
+		return
+	}
+
+	wasFailing := r.failing
+	r.failing = result.ExitCode != 0
+	switch {
+	case r.failing:
+		r.send(fmt.Sprintf("gentr: command failed (exit %d)", result.ExitCode), r.body(result), true)
+	case wasFailing && r.Recovery:
+		r.send("gentr: command recovered", result.Command, false)
+	}
+}
+
+func (r *Reporter) body(result runner.Result) string {
+	lines := strings.Split(strings.TrimSpace(result.RawOutput), "\n")
+	if r.Lines > 0 && len(lines) > r.Lines {
+		lines = append(lines[:r.Lines], "...")
+	}
+	return result.Command + "\n" + strings.Join(lines, "\n")
+}
+
+func (r *Reporter) send(title, body string, urgent bool) {
+	if r.disabled || r.Sender == nil {
+		return
+	}
+	if err := r.Sender.Send(title, body, urgent); err != nil {
+		fmt.Fprintf(r.Warnings, "\n[!] Desktop notification failed, notifications disabled: %v\n", err)
+		r.disabled = true
+	}
+}
+
+func runTool(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type notification struct {
+	title, body string
+	urgent      bool
+}
+
+type fakeSender struct {
+	sent []notification
+	err  error
+}
+
+func (s *fakeSender) Send(title, body string, urgent bool) error {
+	s.sent = append(s.sent, notification{title, body, urgent})
+	return s.err
+}
+
+func TestReporterNotifiesOnFailureAndRecovery(t *testing.T) {
+	sender := &fakeSender{}
+	reporter := NewReporter(sender, true, nil)
+	reporter.Lines = 2
+	opts := config.Options{}
+
+	reporter.Report(runner.Result{ExitCode: 0, Command: "make"}, opts)
+	reporter.Report(runner.Result{ExitCode: 2, Command: "make", RawOutput: "one\ntwo\nthree\n"}, opts)
+	reporter.Report(runner.Result{ExitCode: 1, Command: "notify", Hook: "on-failure"}, opts)
+	reporter.Report(runner.Result{ExitCode: 0, Command: "make"}, opts)
+
+	if len(sender.sent) != 2 {
+		t.Fatalf("expected two notifications, got %+v", sender.sent)
+	}
+	if sender.sent[0].title != "gentr: command failed (exit 2)" || sender.sent[0].body != "make\none\ntwo\n..." || !sender.sent[0].urgent {
+		t.Fatalf("unexpected failure notification: %+v", sender.sent[0])
+	}
+	if sender.sent[1].title != "gentr: command recovered" || sender.sent[1].urgent {
+		t.Fatalf("unexpected recovery notification: %+v", sender.sent[1])
+	}
+}
+
+func TestReporterSkipsRecoveryByDefault(t *testing.T) {
+	sender := &fakeSender{}
+	reporter := NewReporter(sender, false, nil)
+	reporter.Report(runner.Result{ExitCode: 1}, config.Options{})
+	reporter.Report(runner.Result{ExitCode: 0}, config.Options{})
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected only the failure notification, got %+v", sender.sent)
+	}
+}
+
+func TestReporterDisablesItselfWhenSendingFails(t *testing.T) {
+	sender := &fakeSender{err: errors.New("no daemon")}
+	var warnings bytes.Buffer
+	reporter := NewReporter(sender, false, &warnings)
+	reporter.Report(runner.Result{ExitCode: 1}, config.Options{})
+	reporter.Report(runner.Result{ExitCode: 1}, config.Options{})
+
+	if len(sender.sent) != 1 || strings.Count(warnings.String(), "notifications disabled") != 1 {
+		t.Fatalf("sent=%+v warnings=%q", sender.sent, warnings.String())
+	}
+
+	warnings.Reset()
+	NewReporter(nil, false, &warnings).Report(runner.Result{ExitCode: 1}, config.Options{})
+	if !strings.Contains(warnings.String(), "No desktop notification tool found") {
+		t.Fatalf("unexpected warnings: %q", warnings.String())
+	}
+}
+
+func TestNotifySendPassesArguments(t *testing.T) {
+	script, record := recordingTool(t, "notify-send")
+	if err := (NotifySend{Path: script}).Send("-title", "body", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readArguments(t, record); got != "--app-name\ngentr\n--urgency\ncritical\n--\n-title\nbody\n" {
+		t.Fatalf("unexpected arguments: %q", got)
+	}
+}
+
+func TestDBusPassesArguments(t *testing.T) {
+	script, record := recordingTool(t, "gdbus")
+	if err := (DBus{Path: script}).Send("gentr: failed", "make\n\"x\"", false); err != nil {
+		t.Fatal(err)
+	}
+	want := "call\n--session\n--dest\norg.freedesktop.Notifications\n" +
+		"--object-path\n/org/freedesktop/Notifications\n" +
+		"--method\norg.freedesktop.Notifications.Notify\n" +
+		"gentr\n0\n\n\"gentr: failed\"\n\"make\\n\\\"x\\\"\"\n" +
+		"[]\n{'urgency': <byte 1>}\n5000\n"
+	if got := readArguments(t, record); got != want {
+		t.Fatalf("unexpected arguments: %q", got)
+	}
+}
+
+func recordingTool(t *testing.T, name string) (string, string) {
+	t.Helper()
+	directory := t.TempDir()
+	record := filepath.Join(directory, "args")
+	script := filepath.Join(directory, name)
+	content := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + record + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, record
+}
+
+func readArguments(t *testing.T, record string) string {
+	t.Helper()
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -1,10 +1,12 @@
 package output
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,9 @@ type ConsoleReporter struct {
 }
 
 func (r ConsoleReporter) Report(result runner.Result, opts config.Options) {
+	if result.Pipeline {
+		return
+	}
 	writer := r.Writer
 	if writer == nil {
 		writer = os.Stdout
@@ -31,12 +36,34 @@ func (r ConsoleReporter) Report(result runner.Result, opts config.Options) {
 		lines[index] = terminal.TruncateLine(line, 60)
 	}
 
-	fmt.Fprintln(writer, terminal.Bold(terminal.Color("Command Output:", "blue")))
+	title := "Command Output:"
+	switch {
+	case result.Hook != "":
+		title = fmt.Sprintf("Hook Output (%s):", result.Hook)
+	case result.Step != "":
+		title = fmt.Sprintf("Step Output (%s):", result.Step)
+	}
+	fmt.Fprintln(writer, terminal.Bold(terminal.Color(title, "blue")))
 	fmt.Fprintln(writer, strings.Join(lines, "\n"))
 	fmt.Fprintln(writer, terminal.Bold(terminal.Color("Status Log:", "blue")))
 	fmt.Fprintln(writer, formatStatus(result))
 }
 
+func FormatSummary(summary runner.Summary, elapsed time.Duration) string {
+	failures := terminal.Color(fmt.Sprintf("%d failed", summary.Failures), "green")
+	if summary.Failures > 0 {
+		failures = terminal.Color(fmt.Sprintf("%d failed", summary.Failures), "red")
+	}
+	return fmt.Sprintf(
+		"%s %d runs, %s, session time %s, command time %s",
+		terminal.Bold(terminal.Color("Session summary:", "blue")),
+		summary.Runs,
+		failures,
+		elapsed.Round(time.Millisecond),
+		summary.CommandTime.Round(time.Millisecond),
+	)
+}
+
 type SessionLogger struct {
 	path   string
 	output io.Writer
@@ -98,7 +125,112 @@ func (l *SessionLogger) Write(entry string, result runner.Result) error {
 	return writer.Error()
 }
 
+func (l *SessionLogger) WriteRun(path string, result runner.Result) error {
+	if l.path == "" {
+		return fmt.Errorf("session log file not initialized")
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = '\t'
+	if err := writer.Write([]string{
+		runMarker,
+		path,
+		fmt.Sprintf("ExitStatus: %d", result.ExitCode),
+	}); err != nil {
+		return fmt.Errorf("write run record: %w", err)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+const (
+	runSuffix = ": RUN"
+	runMarker = "RUN"
+)
+
+type Session struct {
+	Options string
+	Command string
+	Runs    []RecordedRun
+}
+
+type RecordedRun struct {
+	File     string
+	ExitCode int
+}
+
+func RunEntry(path string) string {
+	return path + runSuffix
+}
+
+func ReadSession(reader io.Reader) (Session, error) {
+	var session Session
+	var body strings.Builder
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# Options: "):
+			session.Options = strings.TrimPrefix(line, "# Options: ")
+		case strings.HasPrefix(line, "# Command: "):
+			session.Command = strings.TrimPrefix(line, "# Command: ")
+		case strings.HasPrefix(line, "#"):
+		default:
+			body.WriteString(line + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Session{}, fmt.Errorf("read session log: %w", err)
+	}
+	if session.Command == "" {
+		return Session{}, fmt.Errorf("session log has no command header")
+	}
+
+	records := csv.NewReader(strings.NewReader(body.String()))
+	records.Comma = '\t'
+	records.FieldsPerRecord = -1
+	records.LazyQuotes = true
+	for {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Session{}, fmt.Errorf("parse session log: %w", err)
+		}
+		if len(record) != 3 || record[0] != runMarker {
+			continue
+		}
+		exitCode, err := strconv.Atoi(strings.TrimPrefix(record[2], "ExitStatus: "))
+		if err != nil {
+			return Session{}, fmt.Errorf("parse exit status %q: %w", record[2], err)
+		}
+		session.Runs = append(session.Runs, RecordedRun{
+			File:     record[1],
+			ExitCode: exitCode,
+		})
+	}
+	return session, nil
+}
+
 func formatStatus(result runner.Result) string {
+	if result.Hook != "" {
+		hook := result
+		hook.Hook = ""
+		return terminal.Bold(terminal.Highlight("hook|"+result.Hook, "white", "blue")) + "|" + formatStatus(hook)
+	}
+	if result.Step != "" {
+		step := result
+		step.Step = ""
+		return terminal.Bold(terminal.Highlight("step|"+result.Step, "white", "magenta")) + "|" + formatStatus(step)
+	}
 	switch {
 	case result.ExitCode == 0:
 		return fmt.Sprintf(
@@ -31,6 +31,13 @@ func TestFormatStatus(t *testing.T) {
 	}
 }
 
+func TestFormatStatusForHook(t *testing.T) {
+	got := terminal.StripANSI(formatStatus(runner.Result{ExitCode: 1, Command: "notify", Hook: "on-failure"}))
+	if got != "hook|on-failure|exit|1|notify" {
+		t.Fatalf("unexpected hook status: %q", got)
+	}
+}
+
 func TestConsoleReporterLimitsOutput(t *testing.T) {
 	var output bytes.Buffer
 	ConsoleReporter{Writer: &output}.Report(
@@ -44,6 +51,15 @@ func TestConsoleReporterLimitsOutput(t *testing.T) {
 	}
 }
 
+func TestFormatSummary(t *testing.T) {
+	summary := runner.Summary{Runs: 3, Failures: 1, CommandTime: 1500 * time.Millisecond}
+	got := terminal.StripANSI(FormatSummary(summary, time.Minute))
+	want := "Session summary: 3 runs, 1 failed, session time 1m0s, command time 1.5s"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
 func TestSessionLoggerInitAndWrite(t *testing.T) {
 	tmp := t.TempDir()
 	oldDirectory, err := os.Getwd()
@@ -70,13 +86,78 @@ func TestSessionLoggerInitAndWrite(t *testing.T) {
 		t.Fatal(err)
 	}
 	text := string(data)
-	for _, expected := range []string{"# Command: go test", "Output\tExitStatus", "file.go:1 ADD: hello", "ExitStatus: 0"} {
+	for _, expected := range []string{"--poll 1s; --poll-max 8s; --debounce 500ms; --rescan 10s", "# Command: go test", "Output\tExitStatus", "file.go:1 ADD: hello", "ExitStatus: 0"} {
 		if !strings.Contains(text, expected) {
 			t.Fatalf("expected %q in:\n%s", expected, text)
 		}
 	}
 }
 
+func TestReadSessionParsesRunRecords(t *testing.T) {
+	text := "# Options: --log true\n# Command: go test /_\n" +
+		strings.Repeat("-", 80) + "\nOutput\tExitStatus\n" + strings.Repeat("-", 80) + "\n" +
+		"RUN\ta b.go\tExitStatus: 0\n" +
+		"a b.go:1 ADD: \"quoted\"\tExitStatus: 0\n" +
+		"a b.go:2 ADD: status: RUN\tExitStatus: 0\n" +
+		RunEntry("legacy.go") + "\tExitStatus: 0\n" +
+		"RUN\tc.go\tExitStatus: 3\n"
+
+	session, err := ReadSession(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Command != "go test /_" || session.Options != "--log true" {
+		t.Fatalf("unexpected header: %+v", session)
+	}
+	want := []RecordedRun{{File: "a b.go", ExitCode: 0}, {File: "c.go", ExitCode: 3}}
+	if len(session.Runs) != len(want) || session.Runs[0] != want[0] || session.Runs[1] != want[1] {
+		t.Fatalf("unexpected runs: %+v", session.Runs)
+	}
+
+	if _, err := ReadSession(strings.NewReader("RUN\ta.go\tExitStatus: 0\n")); err == nil {
+		t.Fatal("expected missing command header to fail")
+	}
+}
+
+func TestSessionLoggerWritesRunsThatDiffEntriesCannotForge(t *testing.T) {
+	oldDirectory, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDirectory)
+
+	logger := NewSessionLogger(nil)
+	logger.now = func() time.Time { return time.Date(2026, 6, 16, 10, 0, 0, 0, time.UTC) }
+	if err := logger.Init(config.New(false, false, ".", 0, true), "cat /_"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Write("notes.txt:1 ADD: status: RUN", runner.Result{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Write("RUN\tforged.txt\tExitStatus: 9", runner.Result{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.WriteRun("notes.txt", runner.Result{ExitCode: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(logger.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	session, err := ReadSession(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Runs) != 1 || session.Runs[0] != (RecordedRun{File: "notes.txt", ExitCode: 1}) {
+		t.Fatalf("unexpected runs: %+v", session.Runs)
+	}
+}
+
 func TestSessionLoggerRejectsWriteBeforeInit(t *testing.T) {
 	if err := NewSessionLogger(nil).Write("entry", runner.Result{}); err == nil {
 		t.Fatal("expected write before initialization to fail")
@@ -86,3 +167,11 @@ func TestSessionLoggerRejectsWriteBeforeInit(t *testing.T) {
 type ioDiscard struct{}
 
 func (ioDiscard) Write(data []byte) (int, error) { return len(data), nil }
+
+func TestConsoleReporterLeavesPipelineResultsToSteps(t *testing.T) {
+	var buffer bytes.Buffer
+	ConsoleReporter{Writer: &buffer}.Report(runner.Result{Command: "pipeline", RawOutput: "ok", Pipeline: true}, config.Options{})
+	if buffer.Len() != 0 {
+		t.Fatalf("pipeline output was printed twice: %q", buffer.String())
+	}
+}
@@ -0,0 +1,151 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type Reporter interface {
+	Report(result runner.Result, opts config.Options)
+}
+
+type MultiReporter []Reporter
+
+func (m MultiReporter) Report(result runner.Result, opts config.Options) {
+	for _, reporter := range m {
+		reporter.Report(result, opts)
+	}
+}
+
+type WebhookPayload struct {
+	Files      []string `json:"files"`
+	Command    string   `json:"command"`
+	ExitCode   int      `json:"exit_code"`
+	DurationMS int64    `json:"duration_ms"`
+	Output     string   `json:"output"`
+	Truncated  bool     `json:"truncated"`
+}
+
+type WebhookReporter struct {
+	URLs      []string
+	On        string
+	Timeout   time.Duration
+	Retries   int
+	Backoff   time.Duration
+	MaxOutput int
+	Errors    io.Writer
+
+	client  *http.Client
+	pending sync.WaitGroup
+}
+
+func NewWebhookReporter(urls []string, on string, timeout time.Duration, retries int, errors io.Writer) *WebhookReporter {
+	if on == "" {
+		on = config.WebhookOnAll
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if errors == nil {
+		errors = io.Discard
+	}
+	return &WebhookReporter{
+		URLs:      urls,
+		On:        on,
+		Timeout:   timeout,
+		Retries:   retries,
+		Backoff:   500 * time.Millisecond,
+		MaxOutput: 4096,
+		Errors:    errors,
+		client:    &http.Client{Timeout: timeout},
+	}
+}
+
+func (r *WebhookReporter) Report(result runner.Result, _ config.Options) {
+	if result.Hook != "" || result.Step != "" || !r.wants(result) {
+		return
+	}
+
+	body, err := json.Marshal(r.payload(result))
+	if err != nil {
+		fmt.Fprintf(r.Errors, "\n[x] Error encoding webhook payload: %v\n", err)
+		return
+	}
+	for _, url := range r.URLs {
+		r.pending.Add(1)
+		go func(url string) {
+			defer r.pending.Done()
+			if err := r.post(url, body); err != nil {
+				fmt.Fprintf(r.Errors, "\n[x] Webhook %s failed: %v\n", url, err)
+			}
+		}(url)
+	}
+}
+
+func (r *WebhookReporter) Flush() {
+	r.pending.Wait()
+}
+
+func (r *WebhookReporter) wants(result runner.Result) bool {
+	switch r.On {
+	case config.WebhookOnSuccess:
+		return result.ExitCode == 0
+	case config.WebhookOnFailure:
+		return result.ExitCode != 0
+	default:
+		return true
+	}
+}
+
+func (r *WebhookReporter) payload(result runner.Result) WebhookPayload {
+	output, truncated := result.RawOutput, false
+	if r.MaxOutput > 0 && len(output) > r.MaxOutput {
+		output, truncated = output[len(output)-r.MaxOutput:], true
+	}
+	return WebhookPayload{
+		Files:      append([]string{}, result.Files...),
+		Command:    result.Command,
+		ExitCode:   result.ExitCode,
+		DurationMS: result.Duration.Milliseconds(),
+		Output:     output,
+		Truncated:  truncated,
+	}
+}
+
+func (r *WebhookReporter) post(url string, body []byte) error {
+	client := r.client
+	if client == nil {
+		client = &http.Client{Timeout: r.Timeout}
+	}
+
+	var err error
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(r.Backoff << (attempt - 1))
+		}
+
+		var response *http.Response
+		response, err = client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+		if response.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("unexpected status %s", response.Status)
+		if response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+	}
+	return fmt.Errorf("after %d attempts: %w", r.Retries+1, err)
+}
@@ -0,0 +1,105 @@
+package output
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type webhookServer struct {
+	mutex    sync.Mutex
+	payloads []WebhookPayload
+	failures int
+}
+
+func (s *webhookServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var payload WebhookPayload
+	if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.payloads = append(s.payloads, payload)
+}
+
+func TestWebhookReporterPostsPayload(t *testing.T) {
+	handler := &webhookServer{failures: 1}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reporter := NewWebhookReporter([]string{server.URL}, config.WebhookOnAll, time.Second, 1, nil)
+	reporter.Backoff = time.Millisecond
+	reporter.MaxOutput = 4
+	reporter.Report(runner.Result{
+		RawOutput: "long output",
+		ExitCode:  2,
+		Command:   "go test a.go",
+		File:      "a.go my notes.txt",
+		Files:     []string{"a.go", "my notes.txt"},
+		Duration:  1500 * time.Millisecond,
+	}, config.Options{})
+	reporter.Flush()
+
+	if len(handler.payloads) != 1 {
+		t.Fatalf("expected one delivered payload, got %+v", handler.payloads)
+	}
+	payload := handler.payloads[0]
+	if payload.Command != "go test a.go" || payload.ExitCode != 2 || payload.DurationMS != 1500 ||
+		payload.Output != "tput" || !payload.Truncated || strings.Join(payload.Files, ",") != "a.go,my notes.txt" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestWebhookReporterFiltersByOutcome(t *testing.T) {
+	handler := &webhookServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reporter := NewWebhookReporter([]string{server.URL}, config.WebhookOnFailure, time.Second, 0, nil)
+	reporter.Report(runner.Result{ExitCode: 0, Command: "ok"}, config.Options{})
+	reporter.Report(runner.Result{ExitCode: 1, Command: "hook", Hook: "on-failure"}, config.Options{})
+	reporter.Report(runner.Result{ExitCode: 1, Command: "failed"}, config.Options{})
+	reporter.Flush()
+
+	if len(handler.payloads) != 1 || handler.payloads[0].Command != "failed" {
+		t.Fatalf("unexpected payloads: %+v", handler.payloads)
+	}
+}
+
+func TestWebhookReporterGivesUpAfterRetries(t *testing.T) {
+	handler := &webhookServer{failures: 5}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	reporter := NewWebhookReporter(nil, config.WebhookOnAll, time.Second, 2, nil)
+	reporter.Backoff = time.Millisecond
+	err := reporter.post(server.URL, []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") || handler.failures != 2 {
+		t.Fatalf("err=%v remaining failures=%d", err, handler.failures)
+	}
+}
+
+func TestMultiReporterFansOut(t *testing.T) {
+	first, second := &countingReporter{}, &countingReporter{}
+	MultiReporter{first, second}.Report(runner.Result{}, config.Options{})
+	if first.count != 1 || second.count != 1 {
+		t.Fatalf("unexpected counts: %d %d", first.count, second.count)
+	}
+}
+
+type countingReporter struct{ count int }
+
+func (r *countingReporter) Report(runner.Result, config.Options) { r.count++ }
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/tiendu/gentr/internal/config"
+	inputpkg "github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type CommandRunner interface {
+	Run(command, file string) runner.Result
+}
+
+type OutputReporter interface {
+	Report(result runner.Result, opts config.Options)
+}
+
+type Pipeline struct {
+	Steps    []config.Step
+	Runner   CommandRunner
+	Reporter OutputReporter
+	Output   io.Writer
+}
+
+func (p Pipeline) Run(files []string, opts config.Options) runner.Result {
+	output := p.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	waves, err := config.Waves(p.Steps)
+	if err != nil {
+		return runner.Result{RawOutput: err.Error(), ExitCode: 1, Command: "pipeline"}
+	}
+
+	overall := runner.Result{Command: "pipeline", File: strings.Join(files, " "), Files: files, Pipeline: true}
+	blocked := make(map[string]bool)
+	for waveIndex, wave := range waves {
+		selected := make([]config.Step, 0, len(wave))
+		for _, step := range wave {
+			switch {
+			case blocked[step.Name]:
+			case !Matches(step, files):
+				fmt.Fprintf(output, "\n[-] Step %s skipped: no changed file matches %s\n", step.Name, strings.Join(step.When, ","))
+			default:
+				selected = append(selected, step)
+			}
+		}
+
+		results := p.runWave(selected, files)
+		for index, result := range results {
+			result.Step = selected[index].Name
+			if p.Reporter != nil {
+				p.Reporter.Report(result, opts)
+			}
+			overall.Duration += result.Duration
+			overall.RawOutput += result.RawOutput
+			if result.ExitCode == 0 {
+				continue
+			}
+			if overall.ExitCode == 0 {
+				overall.ExitCode = result.ExitCode
+				overall.Command = result.Command
+			}
+			if dependents := skipDependents(result.Step, waves[waveIndex+1:], blocked); len(dependents) > 0 {
+				fmt.Fprintf(output, "\n[x] Step %s failed, skipping %s\n", result.Step, strings.Join(dependents, ", "))
+			}
+		}
+	}
+	return overall
+}
+
+func skipDependents(failed string, waves [][]config.Step, blocked map[string]bool) []string {
+	failing := map[string]bool{failed: true}
+	dependents := make([]string, 0)
+	for _, wave := range waves {
+		for _, step := range wave {
+			if blocked[step.Name] {
+				continue
+			}
+			for _, need := range step.Needs {
+				if failing[need] {
+					failing[step.Name] = true
+					blocked[step.Name] = true
+					dependents = append(dependents, step.Name)
+					break
+				}
+			}
+		}
+	}
+	return dependents
+}
+
+func (p Pipeline) runWave(steps []config.Step, files []string) []runner.Result {
+	results := make([]runner.Result, len(steps))
+	var group sync.WaitGroup
+	for index, step := range steps {
+		group.Add(1)
+		go func(index int, step config.Step) {
+			defer group.Done()
+			results[index] = runner.RunFiles(p.Runner, step.Command, files)
+		}(index, step)
+	}
+	group.Wait()
+	return results
+}
+
+func Matches(step config.Step, files []string) bool {
+	if len(step.When) == 0 {
+		return true
+	}
+	for _, pattern := range step.When {
+		for _, file := range files {
+			if inputpkg.MatchPattern(pattern, file) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type fakeRunner struct {
+	mutex    sync.Mutex
+	commands []string
+	codes    map[string]int
+}
+
+func (r *fakeRunner) Run(command, file string) runner.Result {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.commands = append(r.commands, command)
+	return runner.Result{ExitCode: r.codes[command], Command: command, File: file}
+}
+
+type fakeReporter struct{ steps []string }
+
+func (r *fakeReporter) Report(result runner.Result, _ config.Options) {
+	r.steps = append(r.steps, result.Step)
+}
+
+var steps = []config.Step{
+	{Name: "generate", Command: "go generate", When: []string{"*.proto"}},
+	{Name: "build", Command: "go build", Needs: []string{"generate"}},
+	{Name: "test", Command: "go test", Needs: []string{"build"}},
+	{Name: "lint", Command: "go vet", Needs: []string{"build"}},
+	{Name: "restart", Command: "restart", Needs: []string{"test", "lint"}},
+	{Name: "docs", Command: "make docs", When: []string{"docs/*.md"}},
+	{Name: "publish", Command: "publish", Needs: []string{"docs"}},
+}
+
+func TestPipelineRunsStepsInDependencyOrder(t *testing.T) {
+	commandRunner := &fakeRunner{}
+	reporter := &fakeReporter{}
+	var output bytes.Buffer
+
+	result := Pipeline{Steps: steps, Runner: commandRunner, Reporter: reporter, Output: &output}.Run(
+		[]string{"api.proto", "docs/index.md"},
+		config.New(false, false, ".", 0, false),
+	)
+	if result.ExitCode != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := strings.Join(reporter.steps, ","); got != "generate,docs,build,publish,test,lint,restart" {
+		t.Fatalf("unexpected step order: %s", got)
+	}
+}
+
+func TestPipelineStepsSkippedByWhenStillSatisfyNeeds(t *testing.T) {
+	commandRunner := &fakeRunner{}
+	reporter := &fakeReporter{}
+	var output bytes.Buffer
+
+	result := Pipeline{Steps: steps, Runner: commandRunner, Reporter: reporter, Output: &output}.Run(
+		[]string{"main.go"},
+		config.New(false, false, ".", 0, false),
+	)
+	if result.ExitCode != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := strings.Join(reporter.steps, ","); got != "build,publish,test,lint,restart" {
+		t.Fatalf("unexpected step order: %s", got)
+	}
+	if !strings.Contains(output.String(), "Step generate skipped") || !strings.Contains(output.String(), "Step docs skipped") {
+		t.Fatalf("expected skipped steps in output: %q", output.String())
+	}
+}
+
+func TestPipelineSkipsOnlyDependentsOfFailedSteps(t *testing.T) {
+	commandRunner := &fakeRunner{codes: map[string]int{"go build": 3}}
+	reporter := &fakeReporter{}
+	var output bytes.Buffer
+
+	result := Pipeline{Steps: steps, Runner: commandRunner, Reporter: reporter, Output: &output}.Run(
+		[]string{"api.proto", "docs/index.md"},
+		config.New(false, false, ".", 0, false),
+	)
+	if result.ExitCode != 3 || result.Command != "go build" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if got := strings.Join(reporter.steps, ","); got != "generate,docs,build,publish" {
+		t.Fatalf("unexpected step order: %s", got)
+	}
+	if !strings.Contains(output.String(), "Step build failed, skipping test, lint, restart") {
+		t.Fatalf("expected dependents of the failed step to be skipped: %q", output.String())
+	}
+}
+
+func TestMatches(t *testing.T) {
+	step := config.Step{When: []string{"*.go", "docs/*.md"}}
+	if !Matches(step, []string{"cmd/main.go"}) || !Matches(step, []string{"docs/a.md"}) || Matches(step, []string{"a.txt"}) {
+		t.Fatal("unexpected match result")
+	}
+	if !Matches(config.Step{}, nil) {
+		t.Fatal("step without conditions should always match")
+	}
+}
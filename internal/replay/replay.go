@@ -0,0 +1,122 @@
+package replay
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/output"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+type CommandRunner interface {
+	Run(command, file string) runner.Result
+}
+
+type OutputReporter interface {
+	Report(result runner.Result, opts config.Options)
+}
+
+type Command struct {
+	Runner   CommandRunner
+	Reporter OutputReporter
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+func (c Command) Run(args []string) int {
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	var (
+		compare bool
+		length  int
+	)
+	flags := flag.NewFlagSet("gentr replay", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&compare, "compare", false, "Compare exit codes against the recording")
+	flags.IntVar(&length, "length", 0, "Limit output lines")
+	flags.IntVar(&length, "l", 0, "Limit output lines (short)")
+	if err := flags.Parse(args); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "Usage: gentr replay [--compare] [--length N] <logfile>")
+		return 1
+	}
+
+	session, err := readSession(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "[x] Error reading session log: %v\n", err)
+		return 1
+	}
+	if len(session.Runs) == 0 {
+		fmt.Fprintf(stderr, "[x] No recorded runs in %s\n", flags.Arg(0))
+		return 1
+	}
+
+	commandRunner := c.Runner
+	if commandRunner == nil {
+		commandRunner = runner.Shell{}
+	}
+	opts := config.New(false, false, "", length, false)
+
+	mismatches := 0
+	for index, run := range session.Runs {
+		fmt.Fprintf(stdout, "\nReplaying run %d/%d for file: %s\n", index+1, len(session.Runs), run.File)
+		result := commandRunner.Run(session.Command, run.File)
+		if c.Reporter != nil {
+			c.Reporter.Report(result, opts)
+		}
+		if compare && result.ExitCode != run.ExitCode {
+			mismatches++
+			fmt.Fprintf(
+				stdout,
+				"[!] %s: %s recorded exit %d, replayed exit %d\n",
+				classify(run.ExitCode, result.ExitCode),
+				run.File,
+				run.ExitCode,
+				result.ExitCode,
+			)
+		}
+	}
+
+	fmt.Fprintf(stdout, "\nReplayed %d runs of: %s\n", len(session.Runs), session.Command)
+	if !compare {
+		return 0
+	}
+	if mismatches > 0 {
+		fmt.Fprintf(stdout, "[x] %d of %d runs differ from the recording\n", mismatches, len(session.Runs))
+		return 1
+	}
+	fmt.Fprintln(stdout, "[v] All exit codes match the recording")
+	return 0
+}
+
+func readSession(path string) (output.Session, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return output.Session{}, err
+	}
+	defer file.Close()
+	return output.ReadSession(file)
+}
+
+func classify(recorded, replayed int) string {
+	switch {
+	case recorded == 0:
+		return "REGRESSED"
+	case replayed == 0:
+		return "RECOVERED"
+	default:
+		return "CHANGED"
+	}
+}
@@ -0,0 +1,94 @@
+package replay
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+const sessionLog = `# Options: --debug false; --recursive false; --length none; --log true; --input .
+# Command: go test /_
+# Timestamp: 2026-06-16T10:00:00Z
+--------------------------------------------------------------------------------
+Output	ExitStatus
+--------------------------------------------------------------------------------
+RUN	a.go	ExitStatus: 0
+a.go:1 ADD: hello	ExitStatus: 0
+a.go:2 ADD: status: RUN	ExitStatus: 0
+RUN	b.go	ExitStatus: 2
+b.go: DELETED	ExitStatus: -1
+`
+
+type fakeRunner struct {
+	files []string
+	codes map[string]int
+}
+
+func (r *fakeRunner) Run(command, file string) runner.Result {
+	r.files = append(r.files, file)
+	return runner.Result{ExitCode: r.codes[file], Command: strings.ReplaceAll(command, "/_", file)}
+}
+
+type fakeReporter struct{ results []runner.Result }
+
+func (r *fakeReporter) Report(result runner.Result, _ config.Options) {
+	r.results = append(r.results, result)
+}
+
+func TestReplayRerunsRecordedInvocations(t *testing.T) {
+	path := writeSessionLog(t)
+	commandRunner := &fakeRunner{codes: map[string]int{"b.go": 2}}
+	reporter := &fakeReporter{}
+	var stdout bytes.Buffer
+
+	code := Command{Runner: commandRunner, Reporter: reporter, Stdout: &stdout}.Run([]string{path})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if strings.Join(commandRunner.files, ",") != "a.go,b.go" || len(reporter.results) != 2 {
+		t.Fatalf("runner=%+v reporter=%+v", commandRunner, reporter)
+	}
+}
+
+func TestReplayCompareDetectsRegressions(t *testing.T) {
+	path := writeSessionLog(t)
+	commandRunner := &fakeRunner{codes: map[string]int{"a.go": 1, "b.go": 2}}
+	var stdout bytes.Buffer
+
+	code := Command{Runner: commandRunner, Stdout: &stdout}.Run([]string{"--compare", path})
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "REGRESSED: a.go recorded exit 0, replayed exit 1") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+
+	commandRunner.codes["a.go"] = 0
+	if code := (Command{Runner: commandRunner, Stdout: &stdout}).Run([]string{"--compare", path}); code != 0 {
+		t.Fatalf("expected matching replay to return 0, got %d", code)
+	}
+}
+
+func TestReplayRejectsMissingLog(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := (Command{Stderr: &stderr}).Run(nil); code != 1 {
+		t.Fatalf("expected usage error, got %d", code)
+	}
+	if code := (Command{Stderr: &stderr}).Run([]string{filepath.Join(t.TempDir(), "missing.log")}); code != 1 {
+		t.Fatalf("expected missing log error, got %d", code)
+	}
+}
+
+func writeSessionLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.log")
+	if err := os.WriteFile(path, []byte(sessionLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -1,26 +1,119 @@
 package runner
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
 )
 
 type Result struct {
 	RawOutput string
 	ExitCode  int
 	Command   string
+	File      string
+	Files     []string
+	Duration  time.Duration
+	Hook      string
+	Step      string
+	Pipeline  bool
+}
+
+type Summary struct {
+	Runs         int
+	Failures     int
+	LastExitCode int
+	CommandTime  time.Duration
+}
+
+func (s *Summary) Add(result Result) {
+	s.Runs++
+	if result.ExitCode != 0 {
+		s.Failures++
+	}
+	s.LastExitCode = result.ExitCode
+	s.CommandTime += result.Duration
 }
 
 type Runner interface {
 	Run(command, file string) Result
 }
 
+type FilesRunner interface {
+	RunFiles(command string, files []string) Result
+}
+
+func RunFiles(commandRunner Runner, command string, files []string) Result {
+	if filesRunner, ok := commandRunner.(FilesRunner); ok {
+		return filesRunner.RunFiles(command, files)
+	}
+	result := commandRunner.Run(command, Substitution(files))
+	result.File = strings.Join(files, " ")
+	return result
+}
+
+func Substitution(files []string) string {
+	if len(files) == 1 {
+		return files[0]
+	}
+	return QuoteFiles(files)
+}
+
+func QuoteFiles(files []string) string {
+	quoted := make([]string, len(files))
+	for index, file := range files {
+		quoted[index] = quote(file)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quote(file string) string {
+	if file != "" && strings.Trim(file, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./:@%+=,-") == "" {
+		return file
+	}
+	return "'" + strings.ReplaceAll(file, "'", `'\''`) + "'"
+}
+
 type Shell struct{}
 
 func (Shell) Run(command, file string) Result {
+	return run(command, file, nil)
+}
+
+func (Shell) RunHook(command, file string, main *Result) Result {
+	if main == nil {
+		return run(command, file, nil)
+	}
+
+	outputFile, err := os.CreateTemp("", "gentr-output-*.log")
+	if err != nil {
+		return Result{RawOutput: err.Error(), ExitCode: 1, Command: command, File: file}
+	}
+	defer os.Remove(outputFile.Name())
+	_, err = outputFile.WriteString(main.RawOutput)
+	if closeErr := outputFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Result{RawOutput: err.Error(), ExitCode: 1, Command: command, File: file}
+	}
+
+	return run(command, file, []string{
+		fmt.Sprintf("GENTR_EXIT_CODE=%d", main.ExitCode),
+		"GENTR_OUTPUT_FILE=" + outputFile.Name(),
+	})
+}
+
+func run(command, file string, env []string) Result {
 	resolvedCommand := strings.ReplaceAll(command, "/_", file)
 	cmd := exec.Command("sh", "-c", resolvedCommand)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	started := time.Now()
 	output, err := cmd.CombinedOutput()
+	duration := time.Since(started)
 
 	exitCode := 0
 	if err != nil {
@@ -38,5 +131,7 @@ func (Shell) Run(command, file string) Result {
 		RawOutput: string(output),
 		ExitCode:  exitCode,
 		Command:   resolvedCommand,
+		File:      file,
+		Duration:  duration,
 	}
 }
@@ -3,6 +3,7 @@ package runner
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestShellRunsCommand(t *testing.T) {
@@ -14,7 +15,7 @@ func TestShellRunsCommand(t *testing.T) {
 
 func TestShellReplacesPlaceholder(t *testing.T) {
 	result := Shell{}.Run("printf /_", "file.txt")
-	if result.ExitCode != 0 || result.RawOutput != "file.txt" || strings.Contains(result.Command, "/_") {
+	if result.ExitCode != 0 || result.RawOutput != "file.txt" || result.File != "file.txt" || strings.Contains(result.Command, "/_") {
 		t.Fatalf("unexpected result: %+v", result)
 	}
 }
@@ -24,3 +25,65 @@ func TestShellReturnsExitCode(t *testing.T) {
 		t.Fatalf("expected exit code 7, got %+v", result)
 	}
 }
+
+func TestSummaryCountsRunsAndFailures(t *testing.T) {
+	var summary Summary
+	summary.Add(Result{ExitCode: 1, Duration: time.Second})
+	summary.Add(Result{ExitCode: 0, Duration: 2 * time.Second})
+	if summary.Runs != 2 || summary.Failures != 1 || summary.LastExitCode != 0 || summary.CommandTime != 3*time.Second {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestShellRunHookExposesMainResult(t *testing.T) {
+	main := Result{RawOutput: "main output", ExitCode: 3}
+	result := Shell{}.RunHook(`printf "%s:" "$GENTR_EXIT_CODE"; cat "$GENTR_OUTPUT_FILE"; printf ":/_"`, "a.go", &main)
+	if result.ExitCode != 0 || result.RawOutput != "3:main output:a.go" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if result := (Shell{}).RunHook("printf before", "a.go", nil); result.RawOutput != "before" {
+		t.Fatalf("unexpected before hook result: %+v", result)
+	}
+}
+
+type filesRunner struct{ files []string }
+
+func (r *filesRunner) Run(command, file string) Result {
+	return Result{Command: command, File: file}
+}
+
+func (r *filesRunner) RunFiles(command string, files []string) Result {
+	r.files = files
+	return Result{Command: command}
+}
+
+func TestRunFilesPrefersFilesRunner(t *testing.T) {
+	commandRunner := &filesRunner{}
+	RunFiles(commandRunner, "go test", []string{"my dir/a.go", "b.go"})
+	if strings.Join(commandRunner.files, "|") != "my dir/a.go|b.go" {
+		t.Fatalf("expected the file list, got %q", commandRunner.files)
+	}
+	if result := RunFiles(Shell{}, "echo /_", []string{"a.go", "b.go"}); strings.TrimSpace(result.RawOutput) != "a.go b.go" {
+		t.Fatalf("unexpected fallback output: %q", result.RawOutput)
+	}
+}
+
+func TestQuoteFilesKeepsPathsAsSingleShellWords(t *testing.T) {
+	files := []string{"a.go", "my notes.txt", "$(touch pwned)", "it's;rm -rf x", ""}
+	if got := QuoteFiles(files); got != `a.go 'my notes.txt' '$(touch pwned)' 'it'\''s;rm -rf x' ''` {
+		t.Fatalf("unexpected quoting: %s", got)
+	}
+	result := RunFiles(Shell{}, `for f in /_; do echo "[$f]"; done`, files)
+	if want := "[a.go]\n[my notes.txt]\n[$(touch pwned)]\n[it's;rm -rf x]\n[]\n"; result.RawOutput != want {
+		t.Fatalf("expected one shell word per file, got %q", result.RawOutput)
+	}
+	if result.File != strings.Join(files, " ") {
+		t.Fatalf("expected the raw paths in the result, got %q", result.File)
+	}
+
+	result = RunFiles(Shell{}, `echo "[/_]"`, []string{"my notes.txt"})
+	if result.RawOutput != "[my notes.txt]\n" || result.File != "my notes.txt" {
+		t.Fatalf("expected a single file to be substituted as is, got %q for %q", result.RawOutput, result.File)
+	}
+}
@@ -0,0 +1,208 @@
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+func (w *Watcher) watchesDirectories() bool {
+	return w.watchesRecursively() && w.resolver != nil
+}
+
+func (w *Watcher) trackInputDirectories(announce bool) {
+	if !w.watchesDirectories() {
+		return
+	}
+	for _, root := range w.opts.InputRoots() {
+		if !root.Recursive {
+			continue
+		}
+		if info, err := w.filesystem.Stat(root.Path); err == nil && info.IsDir() {
+			w.trackTree(root.Path, announce)
+		}
+	}
+}
+
+func (w *Watcher) rootOf(path string) config.Root {
+	for _, root := range w.opts.InputRoots() {
+		base := filepath.Clean(root.Path)
+		if root.Recursive && (path == base || fsys.Within(base, path)) {
+			return root
+		}
+	}
+	return config.Root{Include: w.opts.Include, Exclude: w.opts.Exclude}
+}
+
+func (w *Watcher) trackTree(root string, announce bool) {
+	err := fs.WalkDir(w.filesystem, filepath.Clean(root), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		w.trackDirectory(path, info.ModTime(), announce)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error tracking directory %s: %v\n", root, err)
+	}
+}
+
+func (w *Watcher) trackDirectory(path string, modTime time.Time, announce bool) {
+	w.mutex.Lock()
+	if _, exists := w.dirs[path]; exists {
+		w.mutex.Unlock()
+		return
+	}
+	w.dirs[path] = modTime
+	w.mutex.Unlock()
+
+	if announce {
+		w.recordEvent(EventDirCreated, path)
+		fmt.Fprintf(w.output, "\n[v] New directory detected and added: %s\n", path)
+	}
+}
+
+func (w *Watcher) isTrackedDirectory(path string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, exists := w.dirs[path]
+	return exists
+}
+
+func (w *Watcher) snapshotDirectories() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	dirs := make([]string, 0, len(w.dirs))
+	for dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func (w *Watcher) scanDirectories() []fileChange {
+	changes := make([]fileChange, 0)
+	deleted := make([]string, 0)
+	for _, dir := range w.snapshotDirectories() {
+		if withinAny(deleted, dir) {
+			continue
+		}
+		info, err := w.filesystem.Stat(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				deleted = append(deleted, dir)
+				changes = append(changes, fileChange{path: dir, deleted: true, directory: true})
+				continue
+			}
+			fmt.Fprintf(w.output, "\n[x] Error stating directory %s: %v\n", dir, err)
+			continue
+		}
+		if w.markDirectoryModified(dir, info.ModTime()) {
+			changes = append(changes, fileChange{path: dir, directory: true})
+		}
+	}
+	return changes
+}
+
+func (w *Watcher) markDirectoryModified(path string, modTime time.Time) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	lastMod, exists := w.dirs[path]
+	if !exists || !modTime.After(lastMod) {
+		return false
+	}
+	w.dirs[path] = modTime
+	return true
+}
+
+func (w *Watcher) syncDirectory(path string, announce bool) {
+	entries, err := w.filesystem.ReadDir(path)
+	if err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error reading directory %s: %v\n", path, err)
+		return
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		if entry.IsDir() && !w.isTrackedDirectory(child) {
+			w.trackTree(child, announce)
+			w.trackResolved(child, true, announce)
+		}
+	}
+	w.trackResolved(path, false, announce)
+}
+
+func (w *Watcher) trackResolved(path string, recursive, announce bool) {
+	root := w.rootOf(path)
+	root.Path, root.Recursive = path, recursive
+	files, err := w.resolveRoot(root)
+	if err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error scanning directory %s: %v\n", path, err)
+		return
+	}
+	for _, file := range files {
+		w.claim(file, root)
+		if err := w.trackFile(file, announce); err != nil {
+			fmt.Fprintf(w.output, "\n[x] Error tracking new file %s: %v\n", file, err)
+		}
+	}
+}
+
+func (w *Watcher) removeDirectory(path string) int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for dir := range w.dirs {
+		if dir == path || fsys.Within(path, dir) {
+			delete(w.dirs, dir)
+		}
+	}
+	removed := 0
+	for file := range w.states {
+		if fsys.Within(path, file) {
+			w.cacheBytes -= contentSize(w.fileContents[file])
+			delete(w.states, file)
+			delete(w.fileContents, file)
+			w.schedule.forget(file)
+			removed++
+		}
+	}
+	w.metrics.SetCacheBytes(w.cacheBytes)
+	w.metrics.SetTrackedFiles(len(w.states))
+	return removed
+}
+
+func (w *Watcher) handleDirectoryDeletion(path string, files int) {
+	w.metrics.Deleted()
+	w.recordEvent(EventDirDeleted, path)
+	fmt.Fprintf(w.output, "\n[!] Directory deleted: %s (%d files)\n", path, files)
+	if !w.opts.Log {
+		return
+	}
+
+	result := runner.Result{ExitCode: -1, Command: "DELETED"}
+	if err := w.logger.Write(fmt.Sprintf("%s: DELETED DIRECTORY (%d files)", path, files), result); err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error writing deletion log: %v\n", err)
+	}
+}
+
+func withinAny(directories []string, path string) bool {
+	for _, directory := range directories {
+		if fsys.Within(directory, path) {
+			return true
+		}
+	}
+	return false
+}
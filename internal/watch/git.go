@@ -0,0 +1,102 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/gitinfo"
+)
+
+type GitMonitor interface {
+	State() gitinfo.State
+	HeadPath() string
+	Poll() (gitinfo.State, bool, error)
+}
+
+func (w *Watcher) SetGit(monitor GitMonitor) {
+	w.git = monitor
+}
+
+func (w *Watcher) expand(command string) string {
+	if w.git == nil {
+		return command
+	}
+	return gitinfo.Expand(command, w.git.State())
+}
+
+func (w *Watcher) expandSteps(steps []config.Step) []config.Step {
+	if w.git == nil {
+		return steps
+	}
+	expanded := make([]config.Step, len(steps))
+	for index, step := range steps {
+		expanded[index] = step
+		expanded[index].Command = w.expand(step.Command)
+	}
+	return expanded
+}
+
+func (w *Watcher) pollGit(ctx context.Context, command string) (int, bool, bool) {
+	if w.git == nil {
+		return 0, false, false
+	}
+	previous, changed, err := w.git.Poll()
+	if err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error reading git state: %v\n", err)
+		return 0, false, false
+	}
+	if !changed {
+		return 0, false, false
+	}
+
+	current := w.git.State()
+	w.recordEvent(EventGit, current.String())
+	if previous.Branch != current.Branch {
+		fmt.Fprintf(w.output, "\n[!] Branch changed: %s -> %s\n", previous.Branch, current.Branch)
+	} else {
+		fmt.Fprintf(w.output, "\n[!] New commit on %s: %s\n", current.Branch, current)
+	}
+
+	if !w.debounce(ctx, w.opts.DebounceDuration) {
+		return 0, true, true
+	}
+
+	if w.resolver != nil {
+		w.rescanQuietly()
+	}
+	changedFiles, deleted := make([]string, 0), 0
+	for _, change := range w.scan(false) {
+		if change.directory && change.deleted {
+			deleted += w.removeDirectory(change.path)
+			continue
+		}
+		if change.directory {
+			w.syncDirectory(change.path, false)
+			continue
+		}
+		if change.deleted {
+			w.removeFile(change.path)
+			deleted++
+			continue
+		}
+		if content, err := w.readFileLines(change.path); err == nil {
+			w.replaceFileContent(change.path, content)
+		}
+		changedFiles = append(changedFiles, change.path)
+	}
+	fmt.Fprintf(w.output, "[!] Collapsed %d changed and %d deleted files into one run\n", len(changedFiles), deleted)
+
+	if w.opts.Wait {
+		return 0, true, true
+	}
+	if len(changedFiles) == 0 {
+		changedFiles = append(changedFiles, w.git.HeadPath())
+	}
+	if w.spinner != nil {
+		w.spinner.Pause()
+		defer w.spinner.Resume()
+	}
+	result := w.execute(changedFiles, command)
+	return result.ExitCode, w.opts.ExitOnChange, true
+}
@@ -0,0 +1,74 @@
+package watch
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+)
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	mode    fs.FileMode
+	uid     uint32
+	gid     uint32
+	inode   uint64
+	target  string
+}
+
+func stateOf(info fs.FileInfo) fileState {
+	state := fileState{modTime: info.ModTime(), size: info.Size(), mode: info.Mode()}
+	state.uid, state.gid, state.inode = ownership(info)
+	return state
+}
+
+func (s fileState) changed(previous fileState, attributes []string) []string {
+	if len(attributes) == 0 {
+		attributes = []string{config.AttributeMtime}
+	}
+	changes := make([]string, 0)
+	if s.target != previous.target {
+		changes = append(changes, config.AttributeTarget)
+	}
+	for _, attribute := range attributes {
+		if s.differs(previous, attribute) && !slices.Contains(changes, attribute) {
+			changes = append(changes, attribute)
+		}
+	}
+	return changes
+}
+
+func (s fileState) differs(previous fileState, attribute string) bool {
+	switch attribute {
+	case config.AttributeMtime:
+		return s.modTime.After(previous.modTime)
+	case config.AttributeSize:
+		return s.size != previous.size
+	case config.AttributeMode:
+		return s.mode != previous.mode
+	case config.AttributeOwner:
+		return s.uid != previous.uid || s.gid != previous.gid
+	case config.AttributeInode:
+		return s.inode != previous.inode
+	}
+	return false
+}
+
+func describeAttributes(attributes []string) string {
+	if len(attributes) == 0 || slices.Equal(attributes, []string{config.AttributeMtime}) {
+		return ""
+	}
+	return " (" + strings.Join(attributes, ", ") + ")"
+}
+
+func (w *Watcher) stateOf(path string, info fs.FileInfo) fileState {
+	state := stateOf(info)
+	if w.opts.FollowSymlinks {
+		state.target, _ = fsys.EvalSymlinks(w.filesystem, path)
+	}
+	return state
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package watch
+
+import "io/fs"
+
+func ownership(fs.FileInfo) (uid, gid uint32, inode uint64) {
+	return 0, 0, 0
+}
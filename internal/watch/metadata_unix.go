@@ -0,0 +1,16 @@
+//go:build unix
+
+package watch
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func ownership(info fs.FileInfo) (uid, gid uint32, inode uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0
+	}
+	return stat.Uid, stat.Gid, uint64(stat.Ino)
+}
@@ -0,0 +1,311 @@
+package watch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tiendu/gentr/internal/clock"
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/logging"
+)
+
+const (
+	hotPolls   = 3
+	paceSlices = 4
+	paceBatch  = 2048
+)
+
+type pollSchedule struct {
+	interval time.Duration
+	ceiling  time.Duration
+	base     cadence
+	cadences map[string]cadence
+	tick     uint64
+	quiet    map[string]int
+	hot      map[string]int
+	pending  [][]string
+	last     pollStats
+}
+
+type cadence struct {
+	poll     time.Duration
+	period   uint64
+	maxLevel int
+}
+
+type pollStats struct {
+	tick    uint64
+	files   int
+	due     int
+	changes int
+	budget  time.Duration
+}
+
+type statResult struct {
+	info fs.FileInfo
+	err  error
+}
+
+func newPollSchedule(interval, poll, ceiling time.Duration) *pollSchedule {
+	s := &pollSchedule{
+		interval: interval,
+		ceiling:  ceiling,
+		cadences: make(map[string]cadence),
+		quiet:    make(map[string]int),
+		hot:      make(map[string]int),
+	}
+	s.base = s.cadence(poll)
+	return s
+}
+
+func (s *pollSchedule) cadence(poll time.Duration) cadence {
+	c := cadence{poll: poll, period: 1}
+	if s.interval > 0 && poll > s.interval {
+		c.period = uint64((poll + s.interval - 1) / s.interval)
+	}
+	if poll > 0 {
+		for poll<<(c.maxLevel+1) <= s.ceiling {
+			c.maxLevel++
+		}
+	}
+	return c
+}
+
+func (s *pollSchedule) assign(path string, poll time.Duration) {
+	if poll == s.base.poll {
+		delete(s.cadences, path)
+		return
+	}
+	s.cadences[path] = s.cadence(poll)
+}
+
+func (s *pollSchedule) cadenceOf(path string) cadence {
+	if c, ok := s.cadences[path]; ok {
+		return c
+	}
+	return s.base
+}
+
+func (s *pollSchedule) level(path string) int {
+	return min(max(s.quiet[path]-hotPolls, 0), s.cadenceOf(path).maxLevel)
+}
+
+func (s *pollSchedule) due(path string) bool {
+	if s.hot[path] > 0 {
+		return true
+	}
+	period := s.cadenceOf(path).period << s.level(path)
+	return (s.tick+slot(path, period))%period == 0
+}
+
+func (s *pollSchedule) observe(path string, changed bool) {
+	if changed {
+		delete(s.quiet, path)
+		s.hot[path] = hotPolls * paceSlices
+		return
+	}
+	if hot := s.hot[path]; hot > 0 {
+		if hot == 1 {
+			delete(s.hot, path)
+		} else {
+			s.hot[path] = hot - 1
+		}
+		return
+	}
+	if quiet := s.quiet[path]; quiet < hotPolls+s.cadenceOf(path).maxLevel {
+		s.quiet[path] = quiet + 1
+	}
+}
+
+func (s *pollSchedule) forget(path string) {
+	delete(s.quiet, path)
+	delete(s.hot, path)
+	delete(s.cadences, path)
+}
+
+func (s *pollSchedule) pace(due []string, tracked map[string]bool) []string {
+	seen := make(map[string]bool, len(due))
+	for _, path := range due {
+		seen[path] = true
+	}
+	for _, slice := range s.pending {
+		for _, path := range slice {
+			if tracked[path] && !seen[path] {
+				seen[path] = true
+				due = append(due, path)
+			}
+		}
+	}
+	s.pending = nil
+	sort.Strings(due)
+	if len(due) <= paceBatch {
+		return due
+	}
+	size := (len(due) + paceSlices - 1) / paceSlices
+	for start := size; start < len(due); start += size {
+		s.pending = append(s.pending, due[start:min(start+size, len(due))])
+	}
+	return due[:size]
+}
+
+func (s *pollSchedule) paced(tracked map[string]bool) []string {
+	due := make([]string, 0, len(s.hot))
+	seen := make(map[string]bool)
+	if len(s.pending) > 0 {
+		for _, path := range s.pending[0] {
+			if tracked[path] {
+				seen[path] = true
+				due = append(due, path)
+			}
+		}
+		s.pending = s.pending[1:]
+	}
+	for path := range s.hot {
+		if tracked[path] && !seen[path] {
+			due = append(due, path)
+		}
+	}
+	sort.Strings(due)
+	return due
+}
+
+func (s *pollSchedule) needsPacing() bool {
+	return len(s.hot) > 0 || len(s.pending) > 0
+}
+
+func (s *pollSchedule) advance() {
+	s.tick++
+}
+
+func (s *pollSchedule) finish(files int, due []string, changes int) {
+	budget := time.Duration(0)
+	for _, path := range due {
+		if poll := s.cadenceOf(path).poll; budget == 0 || poll < budget {
+			budget = poll
+		}
+	}
+	s.last = pollStats{tick: s.tick, files: files, due: len(due), changes: changes, budget: max(budget, s.interval)}
+	s.advance()
+}
+
+func (s *pollSchedule) describe(duration time.Duration) string {
+	backingOff, cold := 0, 0
+	for path := range s.quiet {
+		if s.hot[path] > 0 {
+			continue
+		}
+		switch level := s.level(path); {
+		case level == 0:
+		case level == s.cadenceOf(path).maxLevel:
+			cold++
+		default:
+			backingOff++
+		}
+	}
+	return fmt.Sprintf(
+		"poll %d: %d of %d files due, %d queued, %d hot, %d warm, %d backing off, %d cold at %s, %d changes in %s",
+		s.last.tick, s.last.due, s.last.files, s.queued(), len(s.hot), s.last.files-len(s.hot)-backingOff-cold, backingOff, cold,
+		s.base.poll<<s.base.maxLevel, s.last.changes, duration,
+	)
+}
+
+func (s *pollSchedule) queued() int {
+	queued := 0
+	for _, slice := range s.pending {
+		queued += len(slice)
+	}
+	return queued
+}
+
+func slot(path string, period uint64) uint64 {
+	if period == 1 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(path))
+	return hash.Sum64() % period
+}
+
+func (w *Watcher) claim(path string, root config.Root) {
+	if _, owned := w.owners[path]; owned {
+		return
+	}
+	w.owners[path] = root
+	w.schedule.assign(path, w.opts.PollFor(root))
+}
+
+func (w *Watcher) pace(ticker clock.Ticker) clock.Ticker {
+	switch {
+	case w.schedule.needsPacing() && ticker == nil:
+		return w.clock.NewTicker(max(w.schedule.interval/paceSlices, time.Millisecond))
+	case !w.schedule.needsPacing() && ticker != nil:
+		ticker.Stop()
+		return nil
+	}
+	return ticker
+}
+
+func tickerChannel(ticker clock.Ticker) <-chan time.Time {
+	if ticker == nil {
+		return nil
+	}
+	return ticker.C()
+}
+
+func (w *Watcher) statAll(paths []string) []statResult {
+	results := make([]statResult, len(paths))
+	jobs := make(chan int)
+	var group sync.WaitGroup
+	for range min(max(w.opts.StatWorkers, 1), len(paths)) {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for i := range jobs {
+				info, err := w.filesystem.Stat(paths[i])
+				results[i] = statResult{info: info, err: err}
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	group.Wait()
+	return results
+}
+
+func (w *Watcher) finishPoll(duration time.Duration) {
+	w.metrics.PollFinished(duration)
+	interval := w.schedule.last.budget
+	overrun := interval > 0 && duration > interval
+
+	w.mutex.Lock()
+	w.pollDuration = duration
+	warn := overrun && !w.overrunning
+	w.overrunning = overrun
+	if overrun {
+		w.pollOverruns++
+	}
+	w.mutex.Unlock()
+
+	if overrun {
+		w.metrics.PollOverrun()
+	}
+	if w.debugLog.Enabled(logging.LevelDebug) {
+		w.debugLog.Debugf("%s", w.schedule.describe(duration))
+	}
+	if overrun {
+		w.debugLog.Warnf("poll of %d files took %s, longer than the %s interval", w.schedule.last.due, duration, interval)
+	}
+	if warn {
+		fmt.Fprintf(
+			w.output,
+			"\n[!] Poll of %d files took %s, longer than the %s poll interval; consider raising --stat-workers (now %d)\n",
+			w.schedule.last.due, duration, interval, w.opts.StatWorkers,
+		)
+	}
+}
@@ -0,0 +1,215 @@
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/runner"
+)
+
+const maxEvents = 100
+
+const reloadWait = 2 * time.Second
+
+var (
+	ErrNotRunning = errors.New("watcher is not running")
+	ErrBusy       = errors.New("watcher is busy running a command, try again when it finishes")
+)
+
+const (
+	EventCreated = "created"
+	EventChanged = "changed"
+	EventDeleted = "deleted"
+	EventRun     = "run"
+	EventGit     = "git"
+	EventRemoved = "removed"
+
+	EventDirCreated = "dir_created"
+	EventDirDeleted = "dir_deleted"
+)
+
+type Event struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Path       string    `json:"path"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Attributes []string  `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	State        string    `json:"state"`
+	Started      time.Time `json:"started"`
+	Files        int       `json:"files"`
+	Directories  int       `json:"directories"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	LastExitCode int       `json:"last_exit_code"`
+	PollDuration float64   `json:"poll_duration_seconds"`
+	PollOverruns int       `json:"poll_overruns"`
+}
+
+func (w *Watcher) Status() Status {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	state := "watching"
+	if w.paused {
+		state = "paused"
+	}
+	return Status{
+		State:        state,
+		Started:      w.started,
+		Files:        len(w.states),
+		Directories:  len(w.dirs),
+		Runs:         w.summary.Runs,
+		Failures:     w.summary.Failures,
+		LastExitCode: w.summary.LastExitCode,
+		PollDuration: w.pollDuration.Seconds(),
+		PollOverruns: w.pollOverruns,
+	}
+}
+
+func (w *Watcher) Files() []string {
+	files := w.snapshotFiles()
+	sort.Strings(files)
+	return files
+}
+
+func (w *Watcher) Events() []Event {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]Event(nil), w.events...)
+}
+
+func (w *Watcher) LastResult() (runner.Result, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.lastResult == nil {
+		return runner.Result{}, false
+	}
+	return *w.lastResult, true
+}
+
+func (w *Watcher) Trigger() {
+	select {
+	case w.triggers <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Watcher) Pause() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.paused = true
+}
+
+func (w *Watcher) Resume() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.paused = false
+}
+
+func (w *Watcher) Reload() error {
+	reply := make(chan error, 1)
+	timer := w.clock.NewTimer(reloadWait)
+	defer timer.Stop()
+	select {
+	case w.reloads <- reply:
+		return <-reply
+	case <-timer.C():
+	}
+	if w.isRunning() {
+		return ErrBusy
+	}
+	return ErrNotRunning
+}
+
+func (w *Watcher) Subscribe() (<-chan Event, func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	id := w.nextSubscriber
+	w.nextSubscriber++
+	events := make(chan Event, 64)
+	w.subscribers[id] = events
+
+	return events, func() {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if _, ok := w.subscribers[id]; ok {
+			delete(w.subscribers, id)
+			close(events)
+		}
+	}
+}
+
+func (w *Watcher) isRunning() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.running
+}
+
+func (w *Watcher) setRunning(running bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.running = running
+}
+
+func (w *Watcher) isPaused() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.paused
+}
+
+func (w *Watcher) reload() error {
+	if w.opts.Config != "" {
+		file, err := config.Load(w.opts.Config)
+		if err != nil {
+			return err
+		}
+		w.opts = file.Apply(w.opts)
+	}
+	if w.resolver != nil {
+		w.rescan()
+	}
+	fmt.Fprintln(w.output, "\n[v] Configuration reloaded")
+	return nil
+}
+
+func (w *Watcher) recordRun(path string, result runner.Result) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.summary.Add(result)
+	w.metrics.RunFinished(result.Duration, result.ExitCode)
+	w.lastResult = &result
+	exitCode := result.ExitCode
+	w.appendEvent(Event{Time: w.clock.Now(), Kind: EventRun, Path: path, ExitCode: &exitCode})
+}
+
+func (w *Watcher) recordEvent(kind, path string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.appendEvent(Event{Time: w.clock.Now(), Kind: kind, Path: path})
+}
+
+func (w *Watcher) recordChange(path string, attributes []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.appendEvent(Event{Time: w.clock.Now(), Kind: EventChanged, Path: path, Attributes: attributes})
+}
+
+func (w *Watcher) appendEvent(event Event) {
+	for _, subscriber := range w.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	w.events = append(w.events, event)
+	if len(w.events) > maxEvents {
+		w.events = append([]Event(nil), w.events[len(w.events)-maxEvents:]...)
+	}
+}
@@ -0,0 +1,38 @@
+package watch
+
+import "fmt"
+
+type PathUpdate struct {
+	Path   string
+	Remove bool
+}
+
+func (w *Watcher) SetPathUpdates(updates <-chan PathUpdate) {
+	w.updates = updates
+}
+
+func (w *Watcher) applyUpdate(update PathUpdate) {
+	if update.Remove {
+		w.forgetFile(update.Path)
+		return
+	}
+	if err := w.trackFile(update.Path, true); err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error tracking file %s: %v\n", update.Path, err)
+	}
+}
+
+func (w *Watcher) forgetFile(path string) {
+	if !w.isTrackedFile(path) {
+		return
+	}
+	w.removeFile(path)
+	w.recordEvent(EventRemoved, path)
+	fmt.Fprintf(w.output, "\n[-] Stopped watching: %s\n", path)
+}
+
+func (w *Watcher) isTrackedFile(path string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, exists := w.states[path]
+	return exists
+}
@@ -2,14 +2,23 @@ package watch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/tiendu/gentr/internal/clock"
 	"github.com/tiendu/gentr/internal/config"
 	"github.com/tiendu/gentr/internal/diff"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/logging"
+	"github.com/tiendu/gentr/internal/metrics"
+	"github.com/tiendu/gentr/internal/output"
+	"github.com/tiendu/gentr/internal/pipeline"
 	"github.com/tiendu/gentr/internal/runner"
 	"github.com/tiendu/gentr/internal/terminal"
 )
@@ -25,6 +34,10 @@ type CommandRunner interface {
 	Run(command, file string) runner.Result
 }
 
+type HookRunner interface {
+	RunHook(command, file string, main *runner.Result) runner.Result
+}
+
 type OutputReporter interface {
 	Report(result runner.Result, opts config.Options)
 }
@@ -33,20 +46,52 @@ type ChangeLogger interface {
 	Write(entry string, result runner.Result) error
 }
 
+type RunLogger interface {
+	WriteRun(path string, result runner.Result) error
+}
+
 type Resolver interface {
 	Resolve(input string, recursive bool) ([]string, error)
 }
 
+type RootResolver interface {
+	ResolveRoot(root config.Root) ([]string, error)
+}
+
 type Watcher struct {
-	opts         config.Options
-	spinner      Spinner
-	runner       CommandRunner
-	reporter     OutputReporter
-	logger       ChangeLogger
-	resolver     Resolver
-	output       io.Writer
-	modTimes     map[string]time.Time
-	fileContents map[string][]string
+	opts           config.Options
+	spinner        Spinner
+	runner         CommandRunner
+	reporter       OutputReporter
+	logger         ChangeLogger
+	resolver       Resolver
+	output         io.Writer
+	states         map[string]fileState
+	dirs           map[string]time.Time
+	fileContents   map[string][]string
+	summary        runner.Summary
+	mutex          sync.Mutex
+	started        time.Time
+	paused         bool
+	running        bool
+	events         []Event
+	lastResult     *runner.Result
+	triggers       chan struct{}
+	reloads        chan chan error
+	subscribers    map[int]chan Event
+	nextSubscriber int
+	metrics        *metrics.Set
+	cacheBytes     int
+	git            GitMonitor
+	filesystem     fsys.FS
+	clock          clock.Clock
+	updates        <-chan PathUpdate
+	schedule       *pollSchedule
+	owners         map[string]config.Root
+	pollDuration   time.Duration
+	pollOverruns   int
+	overrunning    bool
+	debugLog       *logging.Logger
 }
 
 func New(
@@ -79,73 +124,321 @@ func New(
 		logger:       logger,
 		resolver:     resolver,
 		output:       output,
-		modTimes:     make(map[string]time.Time),
+		states:       make(map[string]fileState),
+		dirs:         make(map[string]time.Time),
 		fileContents: make(map[string][]string),
+		started:      time.Now(),
+		filesystem:   fsys.OS{},
+		clock:        clock.Real{},
+		triggers:     make(chan struct{}, 1),
+		reloads:      make(chan chan error),
+		subscribers:  make(map[int]chan Event),
+		schedule:     newPollSchedule(opts.PollTick(), opts.PollInterval, opts.MaxPollInterval),
+		owners:       make(map[string]config.Root),
+	}
+}
+
+func (w *Watcher) SetMetrics(set *metrics.Set) {
+	w.metrics = set
+}
+
+func (w *Watcher) SetDebugLogger(logger *logging.Logger) {
+	w.debugLog = logger.Named("watch")
+}
+
+func (w *Watcher) SetFileSystem(filesystem fsys.FS) {
+	w.filesystem = fsys.Or(filesystem)
+}
+
+func (w *Watcher) SetClock(c clock.Clock) {
+	if c == nil {
+		c = clock.Real{}
 	}
+	w.clock = c
+	w.started = c.Now()
 }
 
-func (w *Watcher) Run(ctx context.Context, files []string, command string) {
+func (w *Watcher) Run(ctx context.Context, files []string, command string) int {
+	if w.opts.Once {
+		return w.runOnce(files, command)
+	}
+
 	for _, file := range files {
 		if err := w.trackFile(file, false); err != nil {
 			fmt.Fprintf(w.output, "\n[x] Error tracking file %s: %v\n", file, err)
 		}
 	}
+	if w.opts.OverridesTiming() && w.resolver != nil {
+		w.rescanQuietly()
+	} else {
+		w.trackInputDirectories(false)
+	}
 
-	pollTicker := time.NewTicker(w.opts.PollInterval)
+	pollTicker := w.clock.NewTicker(w.schedule.interval)
 	defer pollTicker.Stop()
+	w.setRunning(true)
+	defer w.setRunning(false)
+	var paceTicker clock.Ticker
+	defer func() {
+		if paceTicker != nil {
+			paceTicker.Stop()
+		}
+	}()
 
-	var rescanTicker *time.Ticker
+	updates := w.updates
 	var rescanChannel <-chan time.Time
-	if w.opts.Recursive && w.resolver != nil {
-		rescanTicker = time.NewTicker(w.opts.RescanInterval)
-		rescanChannel = rescanTicker.C
+	if (w.watchesRecursively() || w.opts.Git) && w.resolver != nil {
+		rescanTicker := w.clock.NewTicker(w.opts.RescanInterval)
+		rescanChannel = rescanTicker.C()
 		defer rescanTicker.Stop()
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
-		case <-pollTicker.C:
-			w.poll(ctx, command)
+			return 0
+		case <-pollTicker.C():
+			if w.isPaused() {
+				continue
+			}
+			if code, done := w.poll(ctx, command); done {
+				return code
+			}
+			paceTicker = w.pace(paceTicker)
+		case <-tickerChannel(paceTicker):
+			if w.isPaused() {
+				continue
+			}
+			if code, done := w.pollPaced(ctx, command); done {
+				return code
+			}
+			paceTicker = w.pace(paceTicker)
 		case <-rescanChannel:
 			w.rescan()
+		case update, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			w.applyUpdate(update)
+		case <-w.triggers:
+			w.runTriggered(command)
+		case reply := <-w.reloads:
+			reply <- w.reload()
+		}
+	}
+}
+
+func (w *Watcher) runOnce(files []string, command string) int {
+	if w.spinner != nil {
+		w.spinner.Pause()
+		defer w.spinner.Resume()
+	}
+
+	fmt.Fprintf(w.output, "\nRunning command once for %d files...\n", len(files))
+	return w.execute(files, command).ExitCode
+}
+
+func (w *Watcher) runTriggered(command string) {
+	files := w.Files()
+	if len(files) == 0 {
+		return
+	}
+	if w.spinner != nil {
+		w.spinner.Pause()
+		defer w.spinner.Resume()
+	}
+
+	fmt.Fprintf(w.output, "\nRun triggered for %d files. Executing command...\n", len(files))
+	w.execute(files, command)
+}
+
+func (w *Watcher) Summary() runner.Summary {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.summary
+}
+
+type fileChange struct {
+	path       string
+	deleted    bool
+	directory  bool
+	attributes []string
+}
+
+func (w *Watcher) poll(ctx context.Context, command string) (int, bool) {
+	if code, done, handled := w.pollGit(ctx, command); handled {
+		return code, done
+	}
+
+	started := w.clock.Now()
+	changes := w.scan(true)
+	w.finishPoll(w.clock.Since(started))
+	return w.handleChanges(ctx, changes, command)
+}
+
+func (w *Watcher) pollPaced(ctx context.Context, command string) (int, bool) {
+	started := w.clock.Now()
+	due := w.schedule.paced(w.trackedSet())
+	changes := w.statDue(due)
+	w.debugLog.Debugf("paced poll: %d files due, %d still queued, %d changes in %s", len(due), w.schedule.queued(), len(changes), w.clock.Since(started))
+	return w.handleChanges(ctx, changes, command)
+}
+
+func (w *Watcher) handleChanges(ctx context.Context, changes []fileChange, command string) (int, bool) {
+	for _, change := range changes {
+		if change.directory && change.deleted {
+			w.handleDirectoryDeletion(change.path, w.removeDirectory(change.path))
+			if w.opts.Wait {
+				return 0, true
+			}
+			continue
+		}
+		if change.directory {
+			w.syncDirectory(change.path, true)
+			continue
+		}
+		if change.deleted {
+			w.removeFile(change.path)
+			w.handleDeletion(change.path)
+			if w.opts.Wait {
+				return 0, true
+			}
+			continue
+		}
+		w.metrics.ChangeDetected()
+		if w.opts.Wait {
+			fmt.Fprintf(w.output, "\nChange detected in file: %s%s\n", change.path, describeAttributes(change.attributes))
+			return 0, true
+		}
+		result, ran := w.handleChange(ctx, change, command)
+		if ran && w.opts.ExitOnChange {
+			return result.ExitCode, true
+		}
+	}
+	return 0, false
+}
+
+func (w *Watcher) scan(paced bool) []fileChange {
+	changes := w.scanDirectories()
+	removed := make([]string, 0)
+	for _, change := range changes {
+		if change.deleted {
+			removed = append(removed, change.path)
+		}
+	}
+	files := w.snapshotFiles()
+	tracked := make(map[string]bool, len(files))
+	due := make([]string, 0)
+	for _, file := range files {
+		if withinAny(removed, file) {
+			continue
+		}
+		tracked[file] = true
+		if w.schedule.due(file) {
+			due = append(due, file)
+		}
+	}
+	due = w.schedule.pace(due, tracked)
+	if !paced {
+		for _, slice := range w.schedule.pending {
+			due = append(due, slice...)
 		}
+		w.schedule.pending = nil
+		sort.Strings(due)
 	}
+	changes = append(changes, w.statDue(due)...)
+	w.schedule.finish(len(files), due, len(changes))
+	return changes
 }
 
-func (w *Watcher) poll(ctx context.Context, command string) {
-	for _, file := range w.snapshotFiles() {
-		info, err := os.Stat(file)
+func (w *Watcher) trackedSet() map[string]bool {
+	files := w.snapshotFiles()
+	tracked := make(map[string]bool, len(files))
+	for _, file := range files {
+		tracked[file] = true
+	}
+	return tracked
+}
+
+func (w *Watcher) statDue(due []string) []fileChange {
+	changes := make([]fileChange, 0)
+	for i, result := range w.statAll(due) {
+		file, info, err := due[i], result.info, result.err
 		if err != nil {
-			if os.IsNotExist(err) {
-				w.removeFile(file)
-				w.handleDeletion(file)
+			if errors.Is(err, fs.ErrNotExist) {
+				w.debugLog.Debugf("stat %s: not found, treating as deleted", file)
+				changes = append(changes, fileChange{path: file, deleted: true})
 				continue
 			}
+			w.debugLog.Warnf("stat %s: %v", file, err)
 			fmt.Fprintf(w.output, "\n[x] Error stating file %s: %v\n", file, err)
 			continue
 		}
-		if !info.IsDir() && w.markModified(file, info.ModTime()) {
-			w.handleChange(ctx, file, command)
+		if info.IsDir() {
+			continue
+		}
+		attributes := w.markModified(file, info)
+		w.schedule.observe(file, len(attributes) > 0)
+		if len(attributes) > 0 {
+			changes = append(changes, fileChange{path: file, attributes: attributes})
 		}
 	}
+	return changes
 }
 
 func (w *Watcher) rescan() {
-	files, err := w.resolver.Resolve(w.opts.Input, true)
-	if err != nil {
-		fmt.Fprintf(w.output, "\n[x] Error rescanning %s: %v\n", w.opts.Input, err)
-		return
+	w.rescanFiles(true)
+}
+
+func (w *Watcher) rescanQuietly() {
+	w.rescanFiles(false)
+}
+
+func (w *Watcher) rescanFiles(announce bool) {
+	started := w.clock.Now()
+	tracked := len(w.snapshotFiles())
+	defer func() {
+		elapsed := w.clock.Since(started)
+		w.metrics.RescanFinished(elapsed)
+		w.debugLog.Debugf("rescan of %d roots added %d files in %s", len(w.opts.InputRoots()), len(w.snapshotFiles())-tracked, elapsed)
+	}()
+
+	for _, root := range w.opts.InputRoots() {
+		files, err := w.resolveRoot(root)
+		if err != nil {
+			fmt.Fprintf(w.output, "\n[x] Error rescanning %s: %v\n", root.Path, err)
+			continue
+		}
+		for _, file := range files {
+			w.claim(file, root)
+			if err := w.trackFile(file, announce); err != nil {
+				fmt.Fprintf(w.output, "\n[x] Error tracking new file %s: %v\n", file, err)
+			}
+		}
 	}
-	for _, file := range files {
-		if err := w.trackFile(file, true); err != nil {
-			fmt.Fprintf(w.output, "\n[x] Error tracking new file %s: %v\n", file, err)
+	w.trackInputDirectories(announce)
+}
+
+func (w *Watcher) resolveRoot(root config.Root) ([]string, error) {
+	if resolver, ok := w.resolver.(RootResolver); ok {
+		return resolver.ResolveRoot(root)
+	}
+	return w.resolver.Resolve(root.Path, root.Recursive)
+}
+
+func (w *Watcher) watchesRecursively() bool {
+	for _, root := range w.opts.InputRoots() {
+		if root.Recursive {
+			return true
 		}
 	}
+	return false
 }
 
 func (w *Watcher) handleDeletion(path string) {
+	w.metrics.Deleted()
+	w.recordEvent(EventDeleted, path)
 	fmt.Fprintf(w.output, "\n[!] File deleted: %s\n", path)
 	if !w.opts.Log {
 		return
@@ -157,31 +450,146 @@ func (w *Watcher) handleDeletion(path string) {
 	}
 }
 
-func (w *Watcher) handleChange(ctx context.Context, path, command string) {
+func (w *Watcher) handleChange(ctx context.Context, change fileChange, command string) (runner.Result, bool) {
+	path := change.path
 	if w.spinner != nil {
 		w.spinner.Pause()
 		defer w.spinner.Resume()
 	}
 
-	timer := time.NewTimer(w.opts.DebounceDuration)
-	defer timer.Stop()
-	select {
-	case <-ctx.Done():
-		return
-	case <-timer.C:
+	if !w.debounce(ctx, w.opts.DebounceFor(w.owners[path])) {
+		return runner.Result{}, false
 	}
 
-	fmt.Fprintf(w.output, "\nChange detected in file: %s. Executing command...\n", path)
-	newContent, err := readFileLines(path)
+	w.recordChange(path, change.attributes)
+	fmt.Fprintf(w.output, "\nChange detected in file: %s%s. Executing command...\n", path, describeAttributes(change.attributes))
+	newContent, err := w.readFileLines(path)
 	if err != nil {
 		fmt.Fprintf(w.output, "\n[x] Error reading file %s: %v\n", path, err)
-		return
+		return runner.Result{}, false
 	}
 
 	oldContent := w.replaceFileContent(path, newContent)
-	result := w.runner.Run(command, path)
-	w.reporter.Report(result, w.opts)
+	result := w.execute([]string{path}, command)
 	w.printAndLogDiff(path, oldContent, newContent, result)
+	return result, true
+}
+
+func (w *Watcher) debounce(ctx context.Context, duration time.Duration) bool {
+	started := w.clock.Now()
+	w.debugLog.Debugf("debounce: waiting %s", duration)
+	timer := w.clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		w.debugLog.Debugf("debounce: cancelled after %s", w.clock.Since(started))
+		return false
+	case <-timer.C():
+		w.debugLog.Debugf("debounce: settled after %s", w.clock.Since(started))
+		return true
+	}
+}
+
+func (w *Watcher) execute(files []string, command string) runner.Result {
+	path := strings.Join(files, " ")
+	command = w.expand(command)
+	if w.opts.Before != "" {
+		if before := w.runHook("before", w.opts.Before, files, nil); before.ExitCode != 0 {
+			fmt.Fprintf(w.output, "\n[x] Before hook failed, skipping command for %s\n", path)
+			w.recordRun(path, before)
+			return before
+		}
+	}
+
+	started := w.clock.Now()
+	var result runner.Result
+	if len(w.opts.Pipeline) > 0 {
+		w.debugLog.Debugf("run pipeline of %d steps for %s", len(w.opts.Pipeline), path)
+		result = pipeline.Pipeline{
+			Steps:    w.expandSteps(w.opts.Pipeline),
+			Runner:   w.runner,
+			Reporter: w.tracedReporter(),
+			Output:   w.output,
+		}.Run(files, w.opts)
+		w.debugLog.Debugf("pipeline finished with exit %d in %s", result.ExitCode, w.clock.Since(started))
+	} else {
+		w.debugLog.Debugf("run %q for %s via %T", command, path, w.runner)
+		result = runner.RunFiles(w.runner, command, files)
+		w.debugLog.Debugf("run %q finished with exit %d in %s", command, result.ExitCode, w.clock.Since(started))
+	}
+	result.Files = files
+	w.report(result)
+	w.recordRun(path, result)
+	w.logRun(path, result)
+
+	if result.ExitCode == 0 && w.opts.OnSuccess != "" {
+		w.runHook("on-success", w.opts.OnSuccess, files, &result)
+	}
+	if result.ExitCode != 0 && w.opts.OnFailure != "" {
+		w.runHook("on-failure", w.opts.OnFailure, files, &result)
+	}
+	if w.opts.OnChange != "" {
+		w.runHook("on-change", w.opts.OnChange, files, &result)
+	}
+	return result
+}
+
+func (w *Watcher) runHook(name, command string, files []string, main *runner.Result) runner.Result {
+	command = w.expand(command)
+	path := strings.Join(files, " ")
+	var result runner.Result
+	if hookRunner, ok := w.runner.(HookRunner); ok {
+		result = hookRunner.RunHook(command, runner.Substitution(files), main)
+	} else {
+		result = w.runner.Run(command, runner.Substitution(files))
+	}
+	result.File = path
+	result.Hook = name
+	w.debugLog.Debugf("hook %s %q finished with exit %d in %s", name, command, result.ExitCode, result.Duration)
+	w.report(result)
+	if w.opts.Log {
+		if err := w.logger.Write(fmt.Sprintf("%s: HOOK %s", path, name), result); err != nil {
+			fmt.Fprintf(w.output, "\n[x] Error writing hook log: %v\n", err)
+		}
+	}
+	return result
+}
+
+func (w *Watcher) report(result runner.Result) {
+	w.tracedReporter().Report(result, w.opts)
+}
+
+func (w *Watcher) tracedReporter() tracingReporter {
+	return tracingReporter{next: w.reporter, log: w.debugLog}
+}
+
+type tracingReporter struct {
+	next OutputReporter
+	log  *logging.Logger
+}
+
+func (r tracingReporter) Report(result runner.Result, opts config.Options) {
+	if result.Step != "" {
+		r.log.Debugf("report step %s exit %d with %d output bytes via %T", result.Step, result.ExitCode, len(result.RawOutput), r.next)
+	} else {
+		r.log.Debugf("report exit %d with %d output bytes via %T", result.ExitCode, len(result.RawOutput), r.next)
+	}
+	r.next.Report(result, opts)
+}
+
+func (w *Watcher) logRun(path string, result runner.Result) {
+	if !w.opts.Log {
+		return
+	}
+	var err error
+	if runLogger, ok := w.logger.(RunLogger); ok {
+		err = runLogger.WriteRun(path, result)
+	} else {
+		err = w.logger.Write(output.RunEntry(path), result)
+	}
+	if err != nil {
+		fmt.Fprintf(w.output, "\n[x] Error writing run log: %v\n", err)
+	}
 }
 
 func (w *Watcher) printAndLogDiff(path string, oldContent, newContent []string, result runner.Result) {
@@ -201,57 +609,101 @@ func (w *Watcher) printAndLogDiff(path string, oldContent, newContent []string,
 }
 
 func (w *Watcher) trackFile(path string, announce bool) error {
-	info, err := os.Stat(path)
+	info, err := w.filesystem.Stat(path)
 	if err != nil {
 		return err
 	}
 	if info.IsDir() {
 		return nil
 	}
-	if _, exists := w.modTimes[path]; exists {
+
+	state := w.stateOf(path, info)
+	w.mutex.Lock()
+	if _, exists := w.states[path]; exists {
+		w.mutex.Unlock()
 		return nil
 	}
-
-	w.modTimes[path] = info.ModTime()
-	if content, err := readFileLines(path); err == nil {
+	w.states[path] = state
+	w.schedule.assign(path, w.opts.PollFor(w.owners[path]))
+	if content, err := w.readFileLines(path); err == nil {
 		w.fileContents[path] = content
+		w.cacheBytes += contentSize(content)
 	}
+	w.metrics.SetCacheBytes(w.cacheBytes)
+	w.metrics.SetTrackedFiles(len(w.states))
+	w.mutex.Unlock()
+
 	if announce {
+		w.recordEvent(EventCreated, path)
 		fmt.Fprintf(w.output, "\n[v] New file detected and added: %s\n", path)
 	}
 	return nil
 }
 
 func (w *Watcher) snapshotFiles() []string {
-	files := make([]string, 0, len(w.modTimes))
-	for file := range w.modTimes {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	files := make([]string, 0, len(w.states))
+	for file := range w.states {
 		files = append(files, file)
 	}
 	return files
 }
 
-func (w *Watcher) markModified(path string, modTime time.Time) bool {
-	lastMod, exists := w.modTimes[path]
-	if !exists || !modTime.After(lastMod) {
-		return false
+func (w *Watcher) markModified(path string, info fs.FileInfo) []string {
+	current := w.stateOf(path, info)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	previous, exists := w.states[path]
+	if !exists {
+		return nil
 	}
-	w.modTimes[path] = modTime
-	return true
+	attributes := current.changed(previous, w.opts.Attributes)
+	if len(attributes) == 0 {
+		return nil
+	}
+	if w.debugLog.Enabled(logging.LevelDebug) {
+		w.debugLog.Debugf(
+			"compare %s: mtime %s -> %s, size %d -> %d, changed %v",
+			path, previous.modTime.Format(time.RFC3339Nano), current.modTime.Format(time.RFC3339Nano),
+			previous.size, current.size, attributes,
+		)
+	}
+	w.states[path] = current
+	return attributes
 }
 
 func (w *Watcher) removeFile(path string) {
-	delete(w.modTimes, path)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.cacheBytes -= contentSize(w.fileContents[path])
+	w.metrics.SetCacheBytes(w.cacheBytes)
+	delete(w.states, path)
 	delete(w.fileContents, path)
+	w.schedule.forget(path)
+	w.metrics.SetTrackedFiles(len(w.states))
 }
 
 func (w *Watcher) replaceFileContent(path string, newContent []string) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
 	oldContent := w.fileContents[path]
 	w.fileContents[path] = newContent
+	w.cacheBytes += contentSize(newContent) - contentSize(oldContent)
+	w.metrics.SetCacheBytes(w.cacheBytes)
 	return oldContent
 }
 
-func readFileLines(path string) ([]string, error) {
-	data, err := os.ReadFile(path)
+func contentSize(lines []string) int {
+	size := 0
+	for _, line := range lines {
+		size += len(line) + 1
+	}
+	return size
+}
+
+func (w *Watcher) readFileLines(path string) ([]string, error) {
+	data, err := w.filesystem.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
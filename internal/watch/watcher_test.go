@@ -3,14 +3,27 @@ package watch
 import (
 	"bytes"
 	"context"
-	"os"
-	"path/filepath"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/tiendu/gentr/internal/clock"
 	"github.com/tiendu/gentr/internal/config"
 	"github.com/tiendu/gentr/internal/diff"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/gitinfo"
+	"github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/logging"
+	"github.com/tiendu/gentr/internal/metrics"
+	"github.com/tiendu/gentr/internal/notify"
+	"github.com/tiendu/gentr/internal/output"
 	"github.com/tiendu/gentr/internal/runner"
 	"github.com/tiendu/gentr/internal/terminal"
 )
@@ -34,6 +47,26 @@ func (r *fakeRunner) Run(command, file string) runner.Result {
 	return r.result
 }
 
+type echoRunner struct{}
+
+func (echoRunner) Run(command, file string) runner.Result {
+	return runner.Result{Command: command, File: file}
+}
+
+type hookRunner struct {
+	fakeRunner
+	hooks     []string
+	mainCodes []int
+}
+
+func (r *hookRunner) RunHook(command, file string, main *runner.Result) runner.Result {
+	r.hooks = append(r.hooks, command)
+	if main != nil {
+		r.mainCodes = append(r.mainCodes, main.ExitCode)
+	}
+	return runner.Result{Command: command}
+}
+
 type fakeReporter struct{ results []runner.Result }
 
 func (r *fakeReporter) Report(result runner.Result, _ config.Options) {
@@ -52,8 +85,9 @@ type fakeResolver struct{ files []string }
 func (r fakeResolver) Resolve(string, bool) ([]string, error) { return r.files, nil }
 
 func TestWatcherTracksMarksAndRemovesFiles(t *testing.T) {
-	path := writeTestFile(t, "hello")
 	watcher := New(config.New(false, false, ".", 0, false), nil, nil, nil, nil, nil, nil)
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "hello")
 
 	if err := watcher.trackFile(path, false); err != nil {
 		t.Fatal(err)
@@ -61,12 +95,14 @@ func TestWatcherTracksMarksAndRemovesFiles(t *testing.T) {
 	if len(watcher.snapshotFiles()) != 1 {
 		t.Fatalf("expected one tracked file")
 	}
-	oldMod := watcher.modTimes[path]
-	if watcher.markModified(path, oldMod) {
-		t.Fatal("same modtime should not be modified")
+	info, _ := memory.Stat(path)
+	if attributes := watcher.markModified(path, info); len(attributes) != 0 {
+		t.Fatalf("same modtime should not be modified: %v", attributes)
 	}
-	if !watcher.markModified(path, oldMod.Add(time.Second)) {
-		t.Fatal("newer modtime should be modified")
+	touchLater(memory, fake, path)
+	info, _ = memory.Stat(path)
+	if attributes := watcher.markModified(path, info); len(attributes) != 1 || attributes[0] != config.AttributeMtime {
+		t.Fatalf("newer modtime should be modified: %v", attributes)
 	}
 	watcher.removeFile(path)
 	if len(watcher.snapshotFiles()) != 0 {
@@ -75,23 +111,22 @@ func TestWatcherTracksMarksAndRemovesFiles(t *testing.T) {
 }
 
 func TestHandleChangeUsesInterfaces(t *testing.T) {
-	path := writeTestFile(t, "old\n")
 	opts := config.New(false, false, ".", 0, true)
-	opts.DebounceDuration = time.Millisecond
+	opts.DebounceDuration = 0
 	spinner := &fakeSpinner{}
 	commandRunner := &fakeRunner{result: runner.Result{RawOutput: "ok", ExitCode: 0, Command: "go test"}}
 	reporter := &fakeReporter{}
 	logger := &fakeLogger{}
-	var output bytes.Buffer
-	watcher := New(opts, spinner, commandRunner, reporter, logger, fakeResolver{}, &output)
+	var stdout bytes.Buffer
+	watcher := New(opts, spinner, commandRunner, reporter, logger, fakeResolver{}, &stdout)
+	memory, _ := useFakes(watcher)
+	path := writeTestFile(memory, "old\n")
 
 	if err := watcher.trackFile(path, false); err != nil {
 		t.Fatal(err)
 	}
-	if err := os.WriteFile(path, []byte("new\n"), 0o644); err != nil {
-		t.Fatal(err)
-	}
-	watcher.handleChange(context.Background(), path, "go test /_")
+	memory.WriteFile(path, []byte("new\n"))
+	watcher.handleChange(context.Background(), fileChange{path: path}, "go test /_")
 
 	if spinner.paused != 1 || spinner.resumed != 1 {
 		t.Fatalf("unexpected spinner calls: %+v", spinner)
@@ -99,16 +134,108 @@ func TestHandleChangeUsesInterfaces(t *testing.T) {
 	if len(commandRunner.commands) != 1 || commandRunner.commands[0] != "go test /_" || commandRunner.files[0] != path {
 		t.Fatalf("unexpected runner calls: %+v", commandRunner)
 	}
-	if len(reporter.results) != 1 || len(logger.entries) == 0 {
+	if len(reporter.results) != 1 || len(logger.entries) == 0 || logger.entries[0] != output.RunEntry(path) {
 		t.Fatalf("reporter=%+v logger=%+v", reporter, logger)
 	}
 }
 
-func TestRunStopsWithContext(t *testing.T) {
-	path := writeTestFile(t, "hello")
+func TestExecuteRunsHooksAroundCommand(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.Before, opts.OnSuccess, opts.OnFailure, opts.OnChange = "before", "success", "failure", "always"
+	commandRunner := &hookRunner{fakeRunner: fakeRunner{result: runner.Result{ExitCode: 2}}}
+	reporter := &fakeReporter{}
+	watcher := New(opts, nil, commandRunner, reporter, nil, nil, nil)
+
+	result := watcher.execute([]string{"a.go"}, "make")
+	if result.ExitCode != 2 || strings.Join(commandRunner.hooks, ",") != "before,failure,always" {
+		t.Fatalf("result=%+v hooks=%v", result, commandRunner.hooks)
+	}
+	if len(commandRunner.mainCodes) != 2 || commandRunner.mainCodes[0] != 2 {
+		t.Fatalf("hooks did not receive the main result: %v", commandRunner.mainCodes)
+	}
+	if len(reporter.results) != 4 || reporter.results[0].Hook != "before" || reporter.results[1].Hook != "" {
+		t.Fatalf("unexpected reports: %+v", reporter.results)
+	}
+}
+
+func TestExecuteQuotesFilesOnlyInTheCommand(t *testing.T) {
+	commandRunner := &fakeRunner{}
+	opts := config.New(false, false, ".", 0, false)
+	opts.Before = "check /_"
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+
+	watcher.execute([]string{"a.go", "my notes.txt", "$(reboot)"}, "cat /_")
+	quoted := `a.go 'my notes.txt' '$(reboot)'`
+	if len(commandRunner.files) != 2 || commandRunner.files[0] != quoted || commandRunner.files[1] != quoted {
+		t.Fatalf("unexpected runner calls: %q", commandRunner.files)
+	}
+	raw := "a.go my notes.txt $(reboot)"
+	if events := watcher.Events(); len(events) != 1 || events[0].Path != raw {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if result, ok := watcher.LastResult(); !ok || result.File != raw {
+		t.Fatalf("unexpected result file: %q", result.File)
+	}
+
+	watcher.execute([]string{"my notes.txt"}, `cat "/_"`)
+	if last := commandRunner.files[len(commandRunner.files)-1]; last != "my notes.txt" {
+		t.Fatalf("expected a single file to be substituted as is, got %q", last)
+	}
+}
+
+func TestExecuteReportsPipelineResultToWebhooks(t *testing.T) {
+	payloads := make(chan output.WebhookPayload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var payload output.WebhookPayload
+		json.NewDecoder(request.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer server.Close()
+
+	opts := config.New(false, false, ".", 0, false)
+	opts.Pipeline = []config.Step{{Name: "build", Command: "make"}, {Name: "test", Command: "make test", Needs: []string{"build"}}}
+	webhooks := output.NewWebhookReporter([]string{server.URL}, config.WebhookOnAll, time.Second, 0, nil)
+	watcher := New(opts, nil, &fakeRunner{result: runner.Result{ExitCode: 3}}, webhooks, nil, nil, &bytes.Buffer{})
+
+	watcher.execute([]string{"a.go", "my notes.txt"}, "")
+	webhooks.Flush()
+	close(payloads)
+	sent := make([]output.WebhookPayload, 0)
+	for payload := range payloads {
+		sent = append(sent, payload)
+	}
+	if len(sent) != 1 || sent[0].ExitCode != 3 || !reflect.DeepEqual(sent[0].Files, []string{"a.go", "my notes.txt"}) {
+		t.Fatalf("expected one webhook for the pipeline, got %+v", sent)
+	}
+}
+
+type recordingSender struct{ titles []string }
+
+func (s *recordingSender) Send(title, _ string, _ bool) error {
+	s.titles = append(s.titles, title)
+	return nil
+}
+
+func TestExecuteNotifiesOnPipelineFailure(t *testing.T) {
 	opts := config.New(false, false, ".", 0, false)
-	opts.PollInterval = time.Millisecond
-	watcher := New(opts, nil, nil, nil, nil, nil, nil)
+	opts.Pipeline = []config.Step{{Name: "build", Command: "make"}}
+	sender := &recordingSender{}
+	commandRunner := &fakeRunner{result: runner.Result{ExitCode: 2}}
+	watcher := New(opts, nil, commandRunner, notify.NewReporter(sender, true, nil), nil, nil, &bytes.Buffer{})
+
+	watcher.execute([]string{"a.go"}, "")
+	commandRunner.result.ExitCode = 0
+	watcher.execute([]string{"a.go"}, "")
+	want := []string{"gentr: command failed (exit 2)", "gentr: command recovered"}
+	if !reflect.DeepEqual(sender.titles, want) {
+		t.Fatalf("expected %v, got %v", want, sender.titles)
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	watcher := New(config.New(false, false, ".", 0, false), nil, nil, nil, nil, nil, nil)
+	memory, _ := useFakes(watcher)
+	path := writeTestFile(memory, "hello")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	done := make(chan struct{})
@@ -125,9 +252,746 @@ func TestRunStopsWithContext(t *testing.T) {
 	}
 }
 
+func TestRunPollsAndDebouncesOnClockTicks(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.PollInterval = time.Second
+	opts.DebounceDuration = 500 * time.Millisecond
+	commandRunner := &fakeRunner{}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "old\n")
+	events, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx, []string{path}, "make")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(100 * time.Millisecond)
+	memory.WriteFile(path, []byte("new\n"))
+	fake.Advance(900 * time.Millisecond)
+	fake.BlockUntil(2)
+	if len(watcher.Events()) != 0 {
+		t.Fatal("change should wait for the debounce interval")
+	}
+	fake.Advance(opts.DebounceDuration)
+
+	if event := <-events; event.Kind != EventChanged || !event.Time.Equal(epoch.Add(1500*time.Millisecond)) {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if event := <-events; event.Kind != EventRun || event.Path != path {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+}
+
+func TestPollTracksDirectoriesInRecursiveMode(t *testing.T) {
+	opts := config.New(false, true, "project", 0, false)
+	var stdout bytes.Buffer
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, &stdout)
+	memory, fake := useFakes(watcher)
+	watcher.resolver = input.FileResolver{FS: memory}
+	memory.WriteFile("project/a.txt", []byte("a"))
+	memory.WriteFile("project/old/b.txt", []byte("b"))
+	memory.WriteFile("project/old/deep/c.txt", []byte("c"))
+	for _, path := range []string{"project/a.txt", "project/old/b.txt", "project/old/deep/c.txt"} {
+		if err := watcher.trackFile(path, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher.trackInputDirectories(false)
+	if status := watcher.Status(); status.Directories != 3 {
+		t.Fatalf("expected 3 tracked directories, got %+v", status)
+	}
+
+	fake.Advance(time.Second)
+	memory.WriteFile("project/new/sub/d.txt", []byte("d"))
+	watcher.poll(context.Background(), "true")
+	if files := watcher.Files(); len(files) != 4 || files[1] != "project/new/sub/d.txt" {
+		t.Fatalf("new directory was not scanned immediately: %v", files)
+	}
+
+	fake.Advance(time.Second)
+	memory.Remove("project/old")
+	watcher.poll(context.Background(), "true")
+
+	kinds := make([]string, 0)
+	for _, event := range watcher.Events() {
+		kinds = append(kinds, event.Kind+" "+event.Path)
+	}
+	want := "dir_created project/new,dir_created project/new/sub,created project/new/sub/d.txt,dir_deleted project/old"
+	if strings.Join(kinds, ",") != want {
+		t.Fatalf("unexpected events: %v", kinds)
+	}
+	if !strings.Contains(stdout.String(), "Directory deleted: project/old (2 files)") || strings.Contains(stdout.String(), "File deleted") {
+		t.Fatalf("child deletions were not collapsed: %q", stdout.String())
+	}
+	if status := watcher.Status(); status.Files != 2 || status.Directories != 3 {
+		t.Fatalf("unexpected status after deletion: %+v", status)
+	}
+}
+
+func TestRescanIteratesEveryRoot(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.Roots = []config.Root{
+		{Path: "cmd", Recursive: true, Include: []string{"*.go"}},
+		{Path: "internal", Recursive: true, Exclude: []string{"*_test.go"}},
+		{Path: "config.yaml"},
+	}
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, &bytes.Buffer{})
+	memory, _ := useFakes(watcher)
+	watcher.resolver = input.FileResolver{FS: memory}
+	memory.WriteFile("cmd/main.go", nil)
+	memory.WriteFile("internal/a.go", nil)
+	memory.WriteFile("config.yaml", nil)
+	memory.WriteFile("docs/guide.md", nil)
+	watcher.rescanQuietly()
+
+	memory.WriteFile("cmd/tool/tool.go", nil)
+	memory.WriteFile("cmd/tool/README.md", nil)
+	memory.WriteFile("internal/b.go", nil)
+	memory.WriteFile("internal/b_test.go", nil)
+	watcher.rescan()
+
+	want := []string{"cmd/main.go", "cmd/tool/tool.go", "config.yaml", "internal/a.go", "internal/b.go"}
+	if files := watcher.Files(); !reflect.DeepEqual(files, want) {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	if status := watcher.Status(); status.Directories != 3 {
+		t.Fatalf("expected the directories of both recursive roots, got %+v", status)
+	}
+}
+
+func TestPollDetectsSelectedAttributes(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	opts.Attributes = []string{config.AttributeSize, config.AttributeMode}
+	commandRunner := &fakeRunner{}
+	var stdout bytes.Buffer
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, &stdout)
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "old\n")
+	if err := watcher.trackFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+
+	touchLater(memory, fake, path)
+	watcher.poll(context.Background(), "true")
+	if len(commandRunner.files) != 0 {
+		t.Fatal("mtime is not a selected attribute")
+	}
+
+	memory.Chmod(path, 0o755)
+	watcher.poll(context.Background(), "true")
+	memory.WriteFile(path, []byte("longer\n"))
+	watcher.poll(context.Background(), "true")
+
+	events := watcher.Events()
+	if len(commandRunner.files) != 2 || len(events) != 4 {
+		t.Fatalf("runner=%+v events=%+v", commandRunner, events)
+	}
+	if strings.Join(events[0].Attributes, ",") != "mode" || strings.Join(events[2].Attributes, ",") != "size" {
+		t.Fatalf("unexpected attributes: %+v %+v", events[0], events[2])
+	}
+	if !strings.Contains(stdout.String(), "Change detected in file: "+path+" (mode). Executing command...") {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestFileStateReportsChangedAttributes(t *testing.T) {
+	previous := fileState{modTime: epoch, size: 1, mode: 0o644, uid: 1, gid: 1, inode: 7}
+	current := previous
+	current.uid = 0
+	current.inode = 8
+	current.modTime = epoch.Add(-time.Second)
+
+	got := current.changed(previous, config.Attributes)
+	if strings.Join(got, ",") != "owner,inode" {
+		t.Fatalf("unexpected attributes: %v", got)
+	}
+	if got := current.changed(previous, nil); len(got) != 0 {
+		t.Fatalf("older mtime should not count as a change: %v", got)
+	}
+}
+
+func TestPollDetectsRetargetedSymlinks(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	opts.FollowSymlinks = true
+	commandRunner := &fakeRunner{}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+	memory, fake := useFakes(watcher)
+	memory.WriteFile("releases/v2/app.conf", []byte("v2\n"))
+	fake.Advance(time.Second)
+	memory.WriteFile("releases/v1/app.conf", []byte("v1\n"))
+	memory.Symlink("releases/v1", "current")
+	if err := watcher.trackFile("current/app.conf", false); err != nil {
+		t.Fatal(err)
+	}
+
+	memory.Remove("current")
+	memory.Symlink("releases/v2", "current")
+	watcher.poll(context.Background(), "reload")
+
+	if len(commandRunner.files) != 1 || commandRunner.files[0] != "current/app.conf" {
+		t.Fatalf("retargeted symlink was not detected: %+v", commandRunner)
+	}
+	events := watcher.Events()
+	if len(events) == 0 || strings.Join(events[0].Attributes, ",") != "target" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestPollScheduleBacksOffColdFilesAndSpreadsStats(t *testing.T) {
+	schedule := newPollSchedule(time.Second, time.Second, 8*time.Second)
+	paths := make([]string, 64)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("src/file%02d.go", i)
+	}
+	polls := make(map[string]int)
+	busiest := 0
+	for tick := 0; tick < 40; tick++ {
+		due := 0
+		for _, path := range paths {
+			if schedule.due(path) {
+				due++
+				polls[path]++
+				schedule.observe(path, false)
+			}
+		}
+		if tick >= 24 {
+			busiest = max(busiest, due)
+		}
+		schedule.advance()
+	}
+	if busiest == 0 || busiest > len(paths)/2 {
+		t.Fatalf("cold stats were not spread across ticks: busiest tick had %d of %d files", busiest, len(paths))
+	}
+	for _, path := range paths {
+		if polls[path] < 6 || polls[path] > 3+4+8 {
+			t.Fatalf("%s polled %d times", path, polls[path])
+		}
+	}
+
+	schedule.observe(paths[0], true)
+	for tick := 0; tick < hotPolls; tick++ {
+		if !schedule.due(paths[0]) {
+			t.Fatalf("recently changed file was not due on tick %d", tick)
+		}
+		schedule.observe(paths[0], false)
+		schedule.advance()
+	}
+
+	fixed := newPollSchedule(time.Second, time.Second, time.Second)
+	for tick := 0; tick < 10; tick++ {
+		fixed.observe("a.go", false)
+		fixed.advance()
+	}
+	if !fixed.due("a.go") {
+		t.Fatal("a ceiling equal to the poll interval should disable backoff")
+	}
+}
+
+func TestRootTimingOverridesPollAndDebounce(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.Roots = []config.Root{
+		{Path: "src", PollInterval: 250 * time.Millisecond},
+		{Path: "docs", DebounceDuration: 2 * time.Second},
+	}
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, &bytes.Buffer{})
+	memory, _ := useFakes(watcher)
+	watcher.resolver = input.FileResolver{FS: memory}
+	memory.WriteFile("src/main.go", nil)
+	memory.WriteFile("docs/guide.md", nil)
+	watcher.rescanQuietly()
+
+	if watcher.schedule.interval != 250*time.Millisecond {
+		t.Fatalf("expected the fastest root to set the tick, got %s", watcher.schedule.interval)
+	}
+	polls := make(map[string]int)
+	for range 8 {
+		for _, path := range []string{"src/main.go", "docs/guide.md"} {
+			if watcher.schedule.due(path) {
+				polls[path]++
+			}
+		}
+		watcher.schedule.advance()
+	}
+	if polls["src/main.go"] != 8 || polls["docs/guide.md"] != 2 {
+		t.Fatalf("unexpected polls per root: %v", polls)
+	}
+	if got := opts.DebounceFor(watcher.owners["docs/guide.md"]); got != 2*time.Second {
+		t.Fatalf("expected the docs debounce override, got %s", got)
+	}
+	if got := opts.DebounceFor(watcher.owners["src/main.go"]); got != opts.DebounceDuration {
+		t.Fatalf("expected the default debounce, got %s", got)
+	}
+
+	rounded := newPollSchedule(300*time.Millisecond, time.Second, 8*time.Second)
+	if rounded.base.period != 4 {
+		t.Fatalf("expected a 1s poll on 300ms ticks to round up to 4 ticks, got %d", rounded.base.period)
+	}
+	rounded.finish(1, []string{"a.go"}, 0)
+	if rounded.last.budget != time.Second {
+		t.Fatalf("expected overruns to be measured against the file's own interval, got %s", rounded.last.budget)
+	}
+}
+
+func TestPollPacesLargeDueSetsAcrossTheInterval(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	commandRunner := &fakeRunner{}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, &bytes.Buffer{})
+	memory, fake := useFakes(watcher)
+	paths := make([]string, 4*paceBatch)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("tree/file%05d.txt", i)
+		memory.WriteFile(paths[i], nil)
+		if err := watcher.trackFile(paths[i], false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake.Advance(time.Second)
+	memory.Touch(paths[len(paths)-1])
+	watcher.poll(context.Background(), "make")
+	if due, queued := watcher.schedule.last.due, watcher.schedule.queued(); due != paceBatch || queued != 3*paceBatch {
+		t.Fatalf("expected one slice of %d files stated and the rest queued, got due=%d queued=%d", paceBatch, due, queued)
+	}
+	if !watcher.schedule.needsPacing() || len(commandRunner.commands) != 0 {
+		t.Fatalf("the change in the last slice was handled too early: %v", commandRunner.commands)
+	}
+	for range paceSlices - 1 {
+		watcher.pollPaced(context.Background(), "make")
+	}
+	if watcher.schedule.queued() != 0 || strings.Join(commandRunner.files, ",") != paths[len(paths)-1] {
+		t.Fatalf("queued=%d files=%v", watcher.schedule.queued(), commandRunner.files)
+	}
+}
+
+func TestHotFilesArePolledBetweenTicks(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	commandRunner := &fakeRunner{}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, &bytes.Buffer{})
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "a")
+	memory.WriteFile("project/b.txt", nil)
+	for _, file := range []string{path, "project/b.txt"} {
+		if err := watcher.trackFile(file, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if watcher.schedule.needsPacing() {
+		t.Fatal("new files should not start in the hot tier")
+	}
+
+	touchLater(memory, fake, path)
+	watcher.poll(context.Background(), "make")
+	if !watcher.schedule.needsPacing() {
+		t.Fatal("a changed file should become hot")
+	}
+	fake.Advance(time.Second / paceSlices)
+	memory.Touch(path)
+	memory.Touch("project/b.txt")
+	watcher.pollPaced(context.Background(), "make")
+	if strings.Join(commandRunner.files, ",") != path+","+path {
+		t.Fatalf("expected only the hot file between ticks, got %v", commandRunner.files)
+	}
+
+	for range hotPolls * paceSlices {
+		watcher.pollPaced(context.Background(), "make")
+	}
+	if watcher.schedule.needsPacing() {
+		t.Fatal("a quiet file should leave the hot tier")
+	}
+}
+
+func TestDebugLoggerTracesPollsAndRuns(t *testing.T) {
+	opts := config.New(true, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	var stdout, trace bytes.Buffer
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, &stdout)
+	watcher.SetDebugLogger(logging.New(&trace, logging.LevelDebug))
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "a")
+	if err := watcher.trackFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	for range hotPolls + 1 {
+		watcher.poll(context.Background(), "true")
+	}
+	touchLater(memory, fake, path)
+	for range 2 {
+		watcher.poll(context.Background(), "make")
+	}
+
+	text := trace.String()
+	for _, expected := range []string{
+		"DEBUG watch: poll 3: 1 of 1 files due, 0 queued, 0 hot, 0 warm, 1 backing off, 0 cold at 8s, 0 changes in 0s\n",
+		"DEBUG watch: compare project/a.txt: mtime 2026-01-01T00:00:00Z -> 2026-01-01T00:00:01Z, size 1 -> 1, changed [mtime]\n",
+		"DEBUG watch: debounce: waiting 0s\n",
+		"DEBUG watch: debounce: settled after 0s\n",
+		"DEBUG watch: run \"make\" for project/a.txt via *watch.fakeRunner\n",
+		"DEBUG watch: run \"make\" finished with exit 0 in 0s\n",
+		"DEBUG watch: report exit 0 with 0 output bytes via watch.discardReporter\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, text)
+		}
+	}
+	if count := strings.Count(text, "compare "); count != 1 {
+		t.Fatalf("expected only the changed file to be compared in the trace, got %d lines:\n%s", count, text)
+	}
+	if strings.Contains(stdout.String(), "DEBUG") {
+		t.Fatalf("diagnostics leaked into the watcher output: %q", stdout.String())
+	}
+}
+
+func TestDebugLoggerTracesPipelineStepReports(t *testing.T) {
+	opts := config.New(true, false, ".", 0, false)
+	opts.Pipeline = []config.Step{{Name: "build", Command: "make"}}
+	var trace bytes.Buffer
+	watcher := New(opts, nil, &fakeRunner{}, &fakeReporter{}, nil, nil, &bytes.Buffer{})
+	watcher.SetDebugLogger(logging.New(&trace, logging.LevelDebug))
+
+	watcher.execute([]string{"a.go"}, "")
+	for _, expected := range []string{
+		"DEBUG watch: report step build exit 0 with 0 output bytes via *watch.fakeReporter\n",
+		"DEBUG watch: report exit 0 with 0 output bytes via *watch.fakeReporter\n",
+	} {
+		if !strings.Contains(trace.String(), expected) {
+			t.Fatalf("expected %q in:\n%s", expected, trace.String())
+		}
+	}
+}
+
+func TestPollWarnsOnceWhenPollsOverrunTheInterval(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.StatWorkers = 4
+	var stdout bytes.Buffer
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, &stdout)
+	set := metrics.New()
+	watcher.SetMetrics(set)
+	memory, fake := useFakes(watcher)
+	for i := range 20 {
+		path := fmt.Sprintf("project/%02d.txt", i)
+		memory.WriteFile(path, nil)
+		if err := watcher.trackFile(path, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher.SetFileSystem(slowFS{Memory: memory, clock: fake, delay: 100 * time.Millisecond})
+
+	watcher.poll(context.Background(), "true")
+	watcher.poll(context.Background(), "true")
+	status := watcher.Status()
+	if status.PollOverruns != 2 || status.PollDuration != 2 || set.PollOverruns.Value() != 2 {
+		t.Fatalf("unexpected poll status: %+v overruns=%d", status, set.PollOverruns.Value())
+	}
+	warning := "[!] Poll of 20 files took 2s, longer than the 1s poll interval; consider raising --stat-workers (now 4)"
+	if strings.Count(stdout.String(), warning) != 1 {
+		t.Fatalf("expected one overrun warning, got %q", stdout.String())
+	}
+}
+
+func TestPollHandlesChangesInPathOrder(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	commandRunner := &fakeRunner{}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+	memory, fake := useFakes(watcher)
+	paths := []string{"z.txt", "a.txt", "m/b.txt", "m/a.txt", "b.txt"}
+	for _, path := range paths {
+		memory.WriteFile(path, nil)
+		if err := watcher.trackFile(path, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake.Advance(time.Second)
+	for _, path := range paths {
+		memory.Touch(path)
+	}
+	watcher.poll(context.Background(), "true")
+	want := []string{"a.txt", "b.txt", "m/a.txt", "m/b.txt", "z.txt"}
+	if !reflect.DeepEqual(commandRunner.files, want) {
+		t.Fatalf("changes were not handled in path order: %v", commandRunner.files)
+	}
+}
+
+func TestRunOnceReturnsCommandStatus(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.Once = true
+	commandRunner := &fakeRunner{result: runner.Result{ExitCode: 3}}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+
+	if code := watcher.Run(context.Background(), []string{"a.go", "b.go"}, "gofmt -l /_"); code != 3 {
+		t.Fatalf("expected exit code 3, got %d", code)
+	}
+	if len(commandRunner.files) != 1 || commandRunner.files[0] != "a.go b.go" {
+		t.Fatalf("unexpected runner calls: %+v", commandRunner)
+	}
+	if summary := watcher.Summary(); summary.Runs != 1 || summary.Failures != 1 || summary.LastExitCode != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestPollExitModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     bool
+		wantRuns int
+		wantCode int
+	}{
+		{"wait", true, 0, 0},
+		{"exit-on-change", false, 1, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := config.New(false, false, ".", 0, false)
+			opts.DebounceDuration = 0
+			opts.Wait = test.wait
+			opts.ExitOnChange = !test.wait
+			commandRunner := &fakeRunner{result: runner.Result{ExitCode: 4}}
+			watcher := New(opts, nil, commandRunner, nil, nil, nil, nil)
+			memory, fake := useFakes(watcher)
+			path := writeTestFile(memory, "old\n")
+			if err := watcher.trackFile(path, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, done := watcher.poll(context.Background(), "true"); done {
+				t.Fatal("unchanged file should not finish the watch")
+			}
+			touchLater(memory, fake, path)
+			code, done := watcher.poll(context.Background(), "true")
+			if !done || code != test.wantCode || len(commandRunner.files) != test.wantRuns {
+				t.Fatalf("done=%v code=%d runner=%+v", done, code, commandRunner)
+			}
+		})
+	}
+}
+
+func TestRunAppliesStreamedPathUpdates(t *testing.T) {
+	var stdout bytes.Buffer
+	watcher := New(config.New(false, false, ".", 0, false), nil, &fakeRunner{}, nil, nil, nil, &stdout)
+	memory, _ := useFakes(watcher)
+	memory.WriteFile("a b.txt", nil)
+	memory.WriteFile("c.txt", nil)
+	updates := make(chan PathUpdate)
+	watcher.SetPathUpdates(updates)
+	events, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx, nil, "true")
+		close(done)
+	}()
+
+	updates <- PathUpdate{Path: "a b.txt"}
+	updates <- PathUpdate{Path: "c.txt"}
+	updates <- PathUpdate{Path: "missing.txt"}
+	updates <- PathUpdate{Path: "a b.txt", Remove: true}
+	close(updates)
+
+	kinds := make([]string, 0)
+	for len(kinds) < 3 {
+		event := <-events
+		kinds = append(kinds, event.Kind+" "+event.Path)
+	}
+	cancel()
+	<-done
+	if want := "created a b.txt,created c.txt,removed a b.txt"; strings.Join(kinds, ",") != want {
+		t.Fatalf("unexpected events: %v", kinds)
+	}
+	if files := watcher.Files(); len(files) != 1 || files[0] != "c.txt" {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	if !strings.Contains(stdout.String(), "Error tracking file missing.txt") || !strings.Contains(stdout.String(), "Stopped watching: a b.txt") {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+}
+
+func TestControlMethodsDriveRunningWatcher(t *testing.T) {
+	watcher := New(config.New(false, false, ".", 0, false), nil, echoRunner{}, nil, nil, fakeResolver{}, nil)
+	memory, _ := useFakes(watcher)
+	path := writeTestFile(memory, "hello")
+	runs, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx, []string{path}, "make")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	watcher.Pause()
+	if state := watcher.Status().State; state != "paused" {
+		t.Fatalf("expected paused state, got %q", state)
+	}
+	watcher.Resume()
+	watcher.Trigger()
+
+	<-runs
+	if result, ok := watcher.LastResult(); !ok || result.File != path || watcher.Status().Runs != 1 {
+		t.Fatalf("unexpected result: %+v status=%+v", result, watcher.Status())
+	}
+
+	events := watcher.Events()
+	if len(events) != 1 || events[0].Kind != EventRun || *events[0].ExitCode != 0 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if files := watcher.Files(); len(files) != 1 || files[0] != path {
+		t.Fatalf("unexpected files: %v", files)
+	}
+}
+
+func TestSubscribeReceivesEvents(t *testing.T) {
+	watcher := New(config.New(false, false, ".", 0, false), nil, nil, nil, nil, nil, nil)
+	events, unsubscribe := watcher.Subscribe()
+
+	watcher.recordEvent(EventChanged, "a.go")
+	watcher.recordRun("a.go", runner.Result{ExitCode: 1})
+	if event := <-events; event.Kind != EventChanged || event.Path != "a.go" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if event := <-events; event.Kind != EventRun || *event.ExitCode != 1 {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Fatal("expected channel to be closed after unsubscribe")
+	}
+}
+
+func TestPollRecordsMetrics(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	watcher := New(opts, nil, &fakeRunner{}, nil, nil, nil, nil)
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "hello\n")
+	instruments := metrics.New()
+	watcher.SetMetrics(instruments)
+	if err := watcher.trackFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if instruments.CacheBytes.Value() != 7 {
+		t.Fatalf("unexpected cache size: %v", instruments.CacheBytes.Value())
+	}
+
+	touchLater(memory, fake, path)
+	watcher.poll(context.Background(), "true")
+	memory.Remove(path)
+	watcher.poll(context.Background(), "true")
+
+	if instruments.ChangesDetected.Value() != 1 || instruments.Deletions.Value() != 1 || instruments.PollDuration.Count() != 2 {
+		t.Fatalf("unexpected metrics: changes=%d deletions=%d polls=%d",
+			instruments.ChangesDetected.Value(), instruments.Deletions.Value(), instruments.PollDuration.Count())
+	}
+	if instruments.TrackedFiles.Value() != 0 || instruments.CacheBytes.Value() != 0 {
+		t.Fatalf("unexpected gauges: tracked=%v cache=%v", instruments.TrackedFiles.Value(), instruments.CacheBytes.Value())
+	}
+}
+
+func TestRunMetricsMatchStatusRuns(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.Before = "check"
+	commandRunner := &fakeRunner{result: runner.Result{ExitCode: 1}}
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, &bytes.Buffer{})
+	instruments := metrics.New()
+	watcher.SetMetrics(instruments)
+
+	watcher.execute([]string{"a.go"}, "make")
+	watcher.opts.Before = ""
+	watcher.opts.Pipeline = []config.Step{{Name: "build", Command: "make"}, {Name: "test", Command: "make test"}}
+	commandRunner.result.ExitCode = 0
+	watcher.execute([]string{"a.go"}, "")
+
+	status := watcher.Status()
+	if status.Runs != 2 || status.Failures != 1 || instruments.Runs.Value() != uint64(status.Runs) || instruments.Failures.Value() != uint64(status.Failures) {
+		t.Fatalf("metrics runs=%d failures=%d, status %+v", instruments.Runs.Value(), instruments.Failures.Value(), status)
+	}
+}
+
+type fakeGit struct {
+	state    gitinfo.State
+	previous gitinfo.State
+	changed  bool
+}
+
+func (g *fakeGit) State() gitinfo.State { return g.state }
+func (g *fakeGit) HeadPath() string     { return ".git/HEAD" }
+func (g *fakeGit) Poll() (gitinfo.State, bool, error) {
+	changed := g.changed
+	g.changed = false
+	return g.previous, changed, nil
+}
+
+func TestPollCollapsesBranchSwitchIntoOneRun(t *testing.T) {
+	opts := config.New(false, false, ".", 0, false)
+	opts.DebounceDuration = 0
+	commandRunner := &fakeRunner{}
+	var stdout bytes.Buffer
+	watcher := New(opts, nil, commandRunner, nil, nil, nil, &stdout)
+	memory, fake := useFakes(watcher)
+	first := writeTestFile(memory, "one\n")
+	second := "project/b.txt"
+	memory.WriteFile(second, []byte("two\n"))
+	git := &fakeGit{state: gitinfo.State{Branch: "feature", Commit: "abc"}, previous: gitinfo.State{Branch: "main"}}
+	watcher.SetGit(git)
+	for _, path := range []string{first, second} {
+		if err := watcher.trackFile(path, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	touchLater(memory, fake, first)
+	touchLater(memory, fake, second)
+	git.changed = true
+	watcher.poll(context.Background(), "deploy /git_branch /_")
+
+	if len(commandRunner.commands) != 1 || commandRunner.commands[0] != "deploy feature /_" {
+		t.Fatalf("unexpected runner calls: %+v", commandRunner)
+	}
+	if commandRunner.files[0] != first+" "+second && commandRunner.files[0] != second+" "+first {
+		t.Fatalf("expected both files in one run, got %q", commandRunner.files[0])
+	}
+	if !strings.Contains(stdout.String(), "Branch changed: main -> feature") {
+		t.Fatalf("unexpected output: %q", stdout.String())
+	}
+
+	watcher.poll(context.Background(), "deploy /git_branch /_")
+	if len(commandRunner.commands) != 1 {
+		t.Fatalf("collapsed changes should not run again: %+v", commandRunner)
+	}
+}
+
 func TestReadFileLinesAndFormatDiffEntry(t *testing.T) {
-	path := writeTestFile(t, "a\nb")
-	lines, err := readFileLines(path)
+	watcher := New(config.New(false, false, ".", 0, false), nil, nil, nil, nil, nil, nil)
+	memory, _ := useFakes(watcher)
+	path := writeTestFile(memory, "a\nb")
+	lines, err := watcher.readFileLines(path)
 	if err != nil || len(lines) != 2 {
 		t.Fatalf("lines=%#v err=%v", lines, err)
 	}
@@ -141,11 +1005,86 @@ func TestReadFileLinesAndFormatDiffEntry(t *testing.T) {
 	}
 }
 
-func writeTestFile(t *testing.T, content string) string {
-	t.Helper()
-	path := filepath.Join(t.TempDir(), "a.txt")
-	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
-		t.Fatal(err)
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type slowFS struct {
+	*fsys.Memory
+	clock *clock.Fake
+	delay time.Duration
+}
+
+func (s slowFS) Stat(name string) (fs.FileInfo, error) {
+	s.clock.Advance(s.delay)
+	return s.Memory.Stat(name)
+}
+
+type blockingRunner struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r blockingRunner) Run(command, file string) runner.Result {
+	r.started <- struct{}{}
+	<-r.release
+	return runner.Result{Command: command, File: file}
+}
+
+func TestReloadReportsStoppedAndBusyWatcher(t *testing.T) {
+	commandRunner := blockingRunner{started: make(chan struct{}), release: make(chan struct{})}
+	watcher := New(config.New(false, false, ".", 0, false), nil, commandRunner, nil, nil, fakeResolver{}, nil)
+	memory, fake := useFakes(watcher)
+	path := writeTestFile(memory, "hello")
+
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- watcher.Reload() }()
+	fake.BlockUntil(1)
+	fake.Advance(reloadWait)
+	if err := <-reloaded; !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx, []string{path}, "make")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	fake.BlockUntil(1)
+	watcher.Trigger()
+	<-commandRunner.started
+	go func() { reloaded <- watcher.Reload() }()
+	fake.BlockUntil(2)
+	fake.Advance(reloadWait)
+	if err := <-reloaded; !errors.Is(err, ErrBusy) {
+		t.Fatalf("expected ErrBusy, got %v", err)
+	}
+	close(commandRunner.release)
+
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("reload after the run failed: %v", err)
 	}
+}
+
+func useFakes(watcher *Watcher) (*fsys.Memory, *clock.Fake) {
+	fake := clock.NewFake(epoch)
+	memory := fsys.NewMemory(fake)
+	watcher.SetClock(fake)
+	watcher.SetFileSystem(memory)
+	return memory, fake
+}
+
+func touchLater(memory *fsys.Memory, fake *clock.Fake, path string) {
+	fake.Advance(time.Second)
+	memory.Touch(path)
+}
+
+func writeTestFile(memory *fsys.Memory, content string) string {
+	path := "project/a.txt"
+	memory.WriteFile(path, []byte(content))
 	return path
 }
@@ -0,0 +1,277 @@
+// Package gentr embeds the gentr file watcher in other Go programs.
+package gentr
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/tiendu/gentr/internal/engine"
+	"github.com/tiendu/gentr/internal/watch"
+)
+
+const (
+	EventCreated = watch.EventCreated
+	EventChanged = watch.EventChanged
+	EventDeleted = watch.EventDeleted
+	EventRun     = watch.EventRun
+	EventGit     = watch.EventGit
+)
+
+var (
+	ErrNoFiles    = engine.ErrNoFiles
+	ErrBusy       = watch.ErrBusy
+	ErrNotRunning = watch.ErrNotRunning
+)
+
+type Resolver interface {
+	Resolve(input string, recursive bool) ([]string, error)
+}
+
+type RootResolver interface {
+	ResolveRoot(root Root) ([]string, error)
+}
+
+type CommandRunner interface {
+	Run(command, file string) Result
+}
+
+type OutputReporter interface {
+	Report(result Result, opts Options)
+}
+
+type ChangeLogger interface {
+	Write(entry string, result Result) error
+}
+
+type Spinner interface {
+	Start()
+	Stop()
+	Pause()
+	Resume()
+}
+
+type GitMonitor interface {
+	State() GitState
+	HeadPath() string
+	Poll() (GitState, bool, error)
+}
+
+type Option func(*settings)
+
+type settings struct {
+	opts     Options
+	files    []string
+	command  string
+	runner   CommandRunner
+	reporter OutputReporter
+	logger   ChangeLogger
+	resolver Resolver
+	spinner  Spinner
+	output   io.Writer
+	metrics  *Metrics
+	git      GitMonitor
+	fs       FileSystem
+	clock    Clock
+	updates  <-chan PathUpdate
+	debugLog *Logger
+	engine   []func(*engine.Config)
+}
+
+func WithOptions(opts Options) Option {
+	return func(s *settings) { s.opts = opts }
+}
+
+func WithInput(input string, recursive bool) Option {
+	return func(s *settings) {
+		s.opts.Input = input
+		s.opts.Recursive = recursive
+		s.opts.Roots = nil
+	}
+}
+
+func WithRoots(roots ...Root) Option {
+	return func(s *settings) { s.opts.Roots = append([]Root(nil), roots...) }
+}
+
+func WithFiles(files ...string) Option {
+	return func(s *settings) { s.files = append([]string(nil), files...) }
+}
+
+func WithCommand(command string) Option {
+	return func(s *settings) { s.command = command }
+}
+
+func WithRunner(commandRunner CommandRunner) Option {
+	return func(s *settings) { s.runner = commandRunner }
+}
+
+func WithReporter(reporter OutputReporter) Option {
+	return func(s *settings) { s.reporter = reporter }
+}
+
+func WithLogger(logger ChangeLogger) Option {
+	return func(s *settings) { s.logger = logger }
+}
+
+func WithResolver(resolver Resolver) Option {
+	return func(s *settings) { s.resolver = resolver }
+}
+
+func WithSpinner(spinner Spinner) Option {
+	return func(s *settings) { s.spinner = spinner }
+}
+
+func WithOutput(writer io.Writer) Option {
+	return func(s *settings) { s.output = writer }
+}
+
+func WithMetrics(set *Metrics) Option {
+	return func(s *settings) { s.metrics = set }
+}
+
+func WithGit(monitor GitMonitor) Option {
+	return func(s *settings) { s.git = monitor }
+}
+
+func WithFileSystem(filesystem FileSystem) Option {
+	return func(s *settings) { s.fs = filesystem }
+}
+
+func WithClock(c Clock) Option {
+	return func(s *settings) { s.clock = c }
+}
+
+func WithPathUpdates(updates <-chan PathUpdate) Option {
+	return func(s *settings) { s.updates = updates }
+}
+
+func WithDebugLogger(logger *Logger) Option {
+	return func(s *settings) { s.debugLog = logger }
+}
+
+func WithEngine(configure func(*engine.Config)) Option {
+	return func(s *settings) { s.engine = append(s.engine, configure) }
+}
+
+type Watcher struct {
+	engine *engine.Engine
+}
+
+func New(options ...Option) (*Watcher, error) {
+	s := settings{opts: DefaultOptions()}
+	for _, option := range options {
+		option(&s)
+	}
+	c := engine.Config{
+		Options:  s.opts.internal(),
+		Files:    s.files,
+		Command:  s.command,
+		Runner:   internalRunner(s.runner),
+		Reporter: internalReporter(s.reporter),
+		Resolver: internalResolver(s.resolver),
+		Spinner:  s.spinner,
+		Output:   s.output,
+		Metrics:  s.metrics.internal(),
+		FS:       internalFS(s.fs),
+		Clock:    internalClock(s.clock),
+		DebugLog: s.debugLog.internal(),
+	}
+	if s.logger != nil {
+		c.Logger = loggerAdapter{logger: s.logger}
+	}
+	if s.git != nil {
+		c.Git = gitAdapter{monitor: s.git}
+	}
+	if s.updates != nil {
+		c.Updates = forwardUpdates(s.updates)
+	}
+	for _, configure := range s.engine {
+		configure(&c)
+	}
+	inner, err := engine.New(c)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{engine: inner}, nil
+}
+
+func (w *Watcher) Run(ctx context.Context) int {
+	return w.engine.Run(ctx)
+}
+
+func (w *Watcher) Subscribe() (<-chan Event, func()) {
+	events, unsubscribe := w.engine.Subscribe()
+	public := make(chan Event, cap(events))
+	done := make(chan struct{})
+	go func() {
+		defer close(public)
+		for event := range events {
+			select {
+			case public <- eventFrom(event):
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return public, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+func (w *Watcher) Summary() Summary {
+	return summaryFrom(w.engine.Summary())
+}
+
+func (w *Watcher) Status() Status {
+	return statusFrom(w.engine.Status())
+}
+
+func (w *Watcher) Files() []string {
+	return w.engine.Files()
+}
+
+func (w *Watcher) Events() []Event {
+	events := w.engine.Events()
+	public := make([]Event, len(events))
+	for index, event := range events {
+		public[index] = eventFrom(event)
+	}
+	return public
+}
+
+func (w *Watcher) LastResult() (Result, bool) {
+	result, ok := w.engine.LastResult()
+	return resultFrom(result), ok
+}
+
+func (w *Watcher) Trigger() {
+	w.engine.Trigger()
+}
+
+func (w *Watcher) Pause() {
+	w.engine.Pause()
+}
+
+func (w *Watcher) Resume() {
+	w.engine.Resume()
+}
+
+func (w *Watcher) Reload() error {
+	return w.engine.Reload()
+}
+
+func forwardUpdates(updates <-chan PathUpdate) <-chan watch.PathUpdate {
+	forwarded := make(chan watch.PathUpdate)
+	go func() {
+		defer close(forwarded)
+		for update := range updates {
+			forwarded <- update.internal()
+		}
+	}()
+	return forwarded
+}
@@ -0,0 +1,257 @@
+package gentr_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/engine"
+	"github.com/tiendu/gentr/pkg/gentr"
+)
+
+type recordingRunner struct {
+	mutex sync.Mutex
+	files []string
+}
+
+func (r *recordingRunner) Run(command, file string) gentr.Result {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.files = append(r.files, file)
+	return gentr.Result{Command: command, File: file}
+}
+
+func (r *recordingRunner) Files() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.files...)
+}
+
+type recordingReporter struct{ results []gentr.Result }
+
+func (r *recordingReporter) Report(result gentr.Result, _ gentr.Options) {
+	r.results = append(r.results, result)
+}
+
+type staticResolver []string
+
+func (r staticResolver) Resolve(string, bool) ([]string, error) {
+	return r, nil
+}
+
+func writeFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("content\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewResolvesInputWithDefaultResolver(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "main.go")
+	writeFile(t, dir, "notes.txt")
+
+	opts := gentr.DefaultOptions()
+	opts.Exclude = []string{"*.txt"}
+	opts.Once = true
+	commandRunner := &recordingRunner{}
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithInput(dir, false),
+		gentr.WithCommand("go test"),
+		gentr.WithRunner(commandRunner),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := watcher.Run(context.Background()); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if files := commandRunner.Files(); len(files) != 1 || files[0] != path {
+		t.Fatalf("expected command for %s, got %v", path, files)
+	}
+}
+
+func TestNewUsesInjectedFileSystem(t *testing.T) {
+	memory := gentr.NewMemoryFS(gentr.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	memory.WriteFile("src/a.go", []byte("package a\n"))
+	memory.WriteFile("src/sub/b.go", []byte("package sub\n"))
+
+	opts := gentr.DefaultOptions()
+	opts.Once = true
+	commandRunner := &recordingRunner{}
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithInput("src", true),
+		gentr.WithFileSystem(memory),
+		gentr.WithRunner(commandRunner),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Run(context.Background())
+	if files := commandRunner.Files(); len(files) != 1 || files[0] != "src/a.go src/sub/b.go" {
+		t.Fatalf("unexpected runner calls: %v", files)
+	}
+}
+
+func TestNewResolvesEveryRoot(t *testing.T) {
+	memory := gentr.NewMemoryFS(nil)
+	memory.WriteFile("cmd/app/main.go", nil)
+	memory.WriteFile("internal/a.go", nil)
+	memory.WriteFile("internal/a_test.go", nil)
+	memory.WriteFile("config.yaml", nil)
+
+	opts := gentr.DefaultOptions()
+	opts.Once = true
+	commandRunner := &recordingRunner{}
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithRoots(
+			gentr.Root{Path: "cmd", Recursive: true},
+			gentr.Root{Path: "internal", Exclude: []string{"*_test.go"}},
+			gentr.Root{Path: "config.yaml"},
+		),
+		gentr.WithFileSystem(memory),
+		gentr.WithRunner(commandRunner),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Run(context.Background())
+	if files := commandRunner.Files(); len(files) != 1 || files[0] != "cmd/app/main.go internal/a.go config.yaml" {
+		t.Fatalf("unexpected runner calls: %v", files)
+	}
+}
+
+func TestNewTracesResolverWithDebugLogger(t *testing.T) {
+	memory := gentr.NewMemoryFS(nil)
+	memory.WriteFile("src/a.go", nil)
+	var trace bytes.Buffer
+
+	_, err := gentr.New(
+		gentr.WithInput("src", false),
+		gentr.WithFileSystem(memory),
+		gentr.WithDebugLogger(gentr.NewDebugLogger(&trace)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(trace.String(), "DEBUG input: resolve src (recursive false, include [], exclude []): 1 files [src/a.go]") {
+		t.Fatalf("unexpected trace: %q", trace.String())
+	}
+}
+
+func TestNewWithoutFilesFails(t *testing.T) {
+	_, err := gentr.New(gentr.WithResolver(staticResolver(nil)))
+	if !errors.Is(err, gentr.ErrNoFiles) {
+		t.Fatalf("expected ErrNoFiles, got %v", err)
+	}
+}
+
+func TestNewRejectsInvalidTiming(t *testing.T) {
+	opts := gentr.DefaultOptions()
+	opts.PollInterval = 0
+	if _, err := gentr.New(gentr.WithOptions(opts), gentr.WithFiles("a.go")); err == nil {
+		t.Fatal("expected an error for a zero poll interval")
+	}
+}
+
+func TestWithEngineAppliesInternalSettingsLast(t *testing.T) {
+	opts := gentr.DefaultOptions()
+	opts.Once = true
+	commandRunner := &recordingRunner{}
+	var configured engine.Config
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithFiles("a.go"),
+		gentr.WithRunner(commandRunner),
+		gentr.WithEngine(func(c *engine.Config) {
+			c.Options.ExitCode = config.ExitCodeAny
+			c.Files = append(c.Files, "b.go")
+			configured = *c
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Run(context.Background())
+	if !configured.Options.Once || configured.Options.ExitCode != config.ExitCodeAny {
+		t.Fatalf("expected public options to reach the hook: %+v", configured.Options)
+	}
+	if files := commandRunner.Files(); len(files) != 1 || files[0] != "a.go b.go" {
+		t.Fatalf("unexpected runner calls: %v", files)
+	}
+}
+
+func TestRunOnceReportsResults(t *testing.T) {
+	dir := t.TempDir()
+	first := writeFile(t, dir, "a.txt")
+	second := writeFile(t, dir, "b.txt")
+
+	opts := gentr.DefaultOptions()
+	opts.Once = true
+	reporter := &recordingReporter{}
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithFiles(first, second),
+		gentr.WithCommand("cat"),
+		gentr.WithRunner(&recordingRunner{}),
+		gentr.WithReporter(reporter),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Run(context.Background())
+
+	if len(reporter.results) != 1 || reporter.results[0].File != first+" "+second {
+		t.Fatalf("expected one result for both files, got %+v", reporter.results)
+	}
+	if summary := watcher.Summary(); summary.Runs != 1 {
+		t.Fatalf("expected 1 run in summary, got %+v", summary)
+	}
+}
+
+func TestSubscribeReceivesRunEvents(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.txt")
+
+	opts := gentr.DefaultOptions()
+	opts.PollInterval = 10 * time.Millisecond
+	watcher, err := gentr.New(
+		gentr.WithOptions(opts),
+		gentr.WithFiles(path),
+		gentr.WithCommand("true"),
+		gentr.WithRunner(&recordingRunner{}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := watcher.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int)
+	go func() { done <- watcher.Run(ctx) }()
+	watcher.Trigger()
+
+	select {
+	case event := <-events:
+		if event.Kind != gentr.EventRun || event.Path != path {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for run event")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,508 @@
+package gentr
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/tiendu/gentr/internal/clock"
+	"github.com/tiendu/gentr/internal/config"
+	"github.com/tiendu/gentr/internal/fsys"
+	"github.com/tiendu/gentr/internal/gitinfo"
+	"github.com/tiendu/gentr/internal/input"
+	"github.com/tiendu/gentr/internal/logging"
+	"github.com/tiendu/gentr/internal/metrics"
+	"github.com/tiendu/gentr/internal/output"
+	"github.com/tiendu/gentr/internal/runner"
+	"github.com/tiendu/gentr/internal/watch"
+)
+
+type Options struct {
+	Input            string
+	Recursive        bool
+	Roots            []Root
+	Include          []string
+	Exclude          []string
+	FollowSymlinks   bool
+	Attributes       []string
+	PollInterval     time.Duration
+	MaxPollInterval  time.Duration
+	StatWorkers      int
+	DebounceDuration time.Duration
+	RescanInterval   time.Duration
+	Once             bool
+	ExitOnChange     bool
+	Wait             bool
+	Before           string
+	OnChange         string
+	OnSuccess        string
+	OnFailure        string
+	Pipeline         []Step
+	Length           int
+	Log              bool
+	Debug            bool
+}
+
+type Root struct {
+	Path             string
+	Recursive        bool
+	Include          []string
+	Exclude          []string
+	PollInterval     time.Duration
+	DebounceDuration time.Duration
+}
+
+type Step struct {
+	Name    string
+	Command string
+	Needs   []string
+	When    []string
+}
+
+type Result struct {
+	RawOutput string
+	ExitCode  int
+	Command   string
+	File      string
+	Files     []string
+	Duration  time.Duration
+	Hook      string
+	Step      string
+	Pipeline  bool
+}
+
+type Summary struct {
+	Runs         int
+	Failures     int
+	LastExitCode int
+	CommandTime  time.Duration
+}
+
+type Event struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Path       string    `json:"path"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Attributes []string  `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	State        string    `json:"state"`
+	Started      time.Time `json:"started"`
+	Files        int       `json:"files"`
+	Directories  int       `json:"directories"`
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	LastExitCode int       `json:"last_exit_code"`
+	PollDuration float64   `json:"poll_duration_seconds"`
+	PollOverruns int       `json:"poll_overruns"`
+}
+
+type GitState struct {
+	Branch string
+	Commit string
+}
+
+func (s GitState) String() string {
+	return s.internal().String()
+}
+
+func (s GitState) internal() gitinfo.State {
+	return gitinfo.State{Branch: s.Branch, Commit: s.Commit}
+}
+
+type PathUpdate struct {
+	Path   string
+	Remove bool
+}
+
+type FileSystem interface {
+	fs.StatFS
+	fs.ReadFileFS
+	fs.ReadDirFS
+	Lstat(name string) (fs.FileInfo, error)
+	ReadLink(name string) (string, error)
+}
+
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type Metrics struct{ set *metrics.Set }
+
+func NewMetrics() *Metrics {
+	return &Metrics{set: metrics.New()}
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return m.internal().Handler()
+}
+
+func (m *Metrics) internal() *metrics.Set {
+	if m == nil {
+		return nil
+	}
+	return m.set
+}
+
+type MemoryFS struct{ memory *fsys.Memory }
+
+func NewMemoryFS(c Clock) *MemoryFS {
+	return &MemoryFS{memory: fsys.NewMemory(internalClock(c))}
+}
+
+func (m *MemoryFS) WriteFile(name string, data []byte)         { m.memory.WriteFile(name, data) }
+func (m *MemoryFS) Mkdir(name string)                          { m.memory.Mkdir(name) }
+func (m *MemoryFS) Touch(name string)                          { m.memory.Touch(name) }
+func (m *MemoryFS) Remove(name string)                         { m.memory.Remove(name) }
+func (m *MemoryFS) Symlink(target, name string)                { m.memory.Symlink(target, name) }
+func (m *MemoryFS) Chmod(name string, mode fs.FileMode)        { m.memory.Chmod(name, mode) }
+func (m *MemoryFS) Open(name string) (fs.File, error)          { return m.memory.Open(name) }
+func (m *MemoryFS) Stat(name string) (fs.FileInfo, error)      { return m.memory.Stat(name) }
+func (m *MemoryFS) Lstat(name string) (fs.FileInfo, error)     { return m.memory.Lstat(name) }
+func (m *MemoryFS) ReadLink(name string) (string, error)       { return m.memory.ReadLink(name) }
+func (m *MemoryFS) ReadFile(name string) ([]byte, error)       { return m.memory.ReadFile(name) }
+func (m *MemoryFS) ReadDir(name string) ([]fs.DirEntry, error) { return m.memory.ReadDir(name) }
+
+type FakeClock struct{ fake *clock.Fake }
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{fake: clock.NewFake(now)}
+}
+
+func (c *FakeClock) Now() time.Time                   { return c.fake.Now() }
+func (c *FakeClock) Since(t time.Time) time.Duration  { return c.fake.Since(t) }
+func (c *FakeClock) NewTicker(d time.Duration) Ticker { return c.fake.NewTicker(d) }
+func (c *FakeClock) NewTimer(d time.Duration) Timer   { return c.fake.NewTimer(d) }
+func (c *FakeClock) Advance(d time.Duration)          { c.fake.Advance(d) }
+func (c *FakeClock) BlockUntil(waiters int)           { c.fake.BlockUntil(waiters) }
+
+type FileResolver struct {
+	Include        []string
+	Exclude        []string
+	FS             FileSystem
+	FollowSymlinks bool
+}
+
+func (r FileResolver) Resolve(path string, recursive bool) ([]string, error) {
+	return r.internal().Resolve(path, recursive)
+}
+
+func (r FileResolver) ResolveRoot(root Root) ([]string, error) {
+	return r.internal().ResolveRoot(root.internal())
+}
+
+func (r FileResolver) internal() input.FileResolver {
+	return input.FileResolver{Include: r.Include, Exclude: r.Exclude, FS: internalFS(r.FS), FollowSymlinks: r.FollowSymlinks}
+}
+
+type ShellRunner struct{}
+
+func (ShellRunner) Run(command, file string) Result {
+	return resultFrom(runner.Shell{}.Run(command, file))
+}
+
+type ConsoleReporter struct {
+	Writer io.Writer
+}
+
+func (r ConsoleReporter) Report(result Result, opts Options) {
+	output.ConsoleReporter{Writer: r.Writer}.Report(result.internal(), opts.internal())
+}
+
+type MultiReporter []OutputReporter
+
+func (m MultiReporter) Report(result Result, opts Options) {
+	for _, reporter := range m {
+		reporter.Report(result, opts)
+	}
+}
+
+func DefaultOptions() Options {
+	return optionsFrom(config.New(false, false, ".", 0, false))
+}
+
+func (o Options) internal() config.Options {
+	opts := config.New(o.Debug, o.Recursive, o.Input, o.Length, o.Log)
+	opts.Include = o.Include
+	opts.Exclude = o.Exclude
+	opts.FollowSymlinks = o.FollowSymlinks
+	opts.Attributes = o.Attributes
+	opts.PollInterval = o.PollInterval
+	opts.MaxPollInterval = o.MaxPollInterval
+	opts.StatWorkers = o.StatWorkers
+	opts.DebounceDuration = o.DebounceDuration
+	opts.RescanInterval = o.RescanInterval
+	opts.Once = o.Once
+	opts.ExitOnChange = o.ExitOnChange
+	opts.Wait = o.Wait
+	opts.Before = o.Before
+	opts.OnChange = o.OnChange
+	opts.OnSuccess = o.OnSuccess
+	opts.OnFailure = o.OnFailure
+	for _, root := range o.Roots {
+		opts.Roots = append(opts.Roots, root.internal())
+	}
+	for _, step := range o.Pipeline {
+		opts.Pipeline = append(opts.Pipeline, step.internal())
+	}
+	return opts
+}
+
+func optionsFrom(opts config.Options) Options {
+	public := Options{
+		Input:            opts.Input,
+		Recursive:        opts.Recursive,
+		Include:          opts.Include,
+		Exclude:          opts.Exclude,
+		FollowSymlinks:   opts.FollowSymlinks,
+		Attributes:       opts.Attributes,
+		PollInterval:     opts.PollInterval,
+		MaxPollInterval:  opts.MaxPollInterval,
+		StatWorkers:      opts.StatWorkers,
+		DebounceDuration: opts.DebounceDuration,
+		RescanInterval:   opts.RescanInterval,
+		Once:             opts.Once,
+		ExitOnChange:     opts.ExitOnChange,
+		Wait:             opts.Wait,
+		Before:           opts.Before,
+		OnChange:         opts.OnChange,
+		OnSuccess:        opts.OnSuccess,
+		OnFailure:        opts.OnFailure,
+		Length:           opts.Length,
+		Log:              opts.Log,
+		Debug:            opts.Debug,
+	}
+	for _, root := range opts.Roots {
+		public.Roots = append(public.Roots, rootFrom(root))
+	}
+	for _, step := range opts.Pipeline {
+		public.Pipeline = append(public.Pipeline, stepFrom(step))
+	}
+	return public
+}
+
+func (r Root) internal() config.Root {
+	return config.Root{
+		Path:             r.Path,
+		Recursive:        r.Recursive,
+		Include:          r.Include,
+		Exclude:          r.Exclude,
+		PollInterval:     r.PollInterval,
+		DebounceDuration: r.DebounceDuration,
+	}
+}
+
+func rootFrom(root config.Root) Root {
+	return Root{
+		Path:             root.Path,
+		Recursive:        root.Recursive,
+		Include:          root.Include,
+		Exclude:          root.Exclude,
+		PollInterval:     root.PollInterval,
+		DebounceDuration: root.DebounceDuration,
+	}
+}
+
+func (s Step) internal() config.Step {
+	return config.Step{Name: s.Name, Command: s.Command, Needs: s.Needs, When: s.When}
+}
+
+func stepFrom(step config.Step) Step {
+	return Step{Name: step.Name, Command: step.Command, Needs: step.Needs, When: step.When}
+}
+
+func (r Result) internal() runner.Result {
+	return runner.Result{
+		RawOutput: r.RawOutput,
+		ExitCode:  r.ExitCode,
+		Command:   r.Command,
+		File:      r.File,
+		Files:     r.Files,
+		Duration:  r.Duration,
+		Hook:      r.Hook,
+		Step:      r.Step,
+		Pipeline:  r.Pipeline,
+	}
+}
+
+func resultFrom(result runner.Result) Result {
+	return Result{
+		RawOutput: result.RawOutput,
+		ExitCode:  result.ExitCode,
+		Command:   result.Command,
+		File:      result.File,
+		Files:     result.Files,
+		Duration:  result.Duration,
+		Hook:      result.Hook,
+		Step:      result.Step,
+		Pipeline:  result.Pipeline,
+	}
+}
+
+func summaryFrom(summary runner.Summary) Summary {
+	return Summary{
+		Runs:         summary.Runs,
+		Failures:     summary.Failures,
+		LastExitCode: summary.LastExitCode,
+		CommandTime:  summary.CommandTime,
+	}
+}
+
+func eventFrom(event watch.Event) Event {
+	return Event{
+		Time:       event.Time,
+		Kind:       event.Kind,
+		Path:       event.Path,
+		ExitCode:   event.ExitCode,
+		Attributes: event.Attributes,
+	}
+}
+
+func statusFrom(status watch.Status) Status {
+	return Status{
+		State:        status.State,
+		Started:      status.Started,
+		Files:        status.Files,
+		Directories:  status.Directories,
+		Runs:         status.Runs,
+		Failures:     status.Failures,
+		LastExitCode: status.LastExitCode,
+		PollDuration: status.PollDuration,
+		PollOverruns: status.PollOverruns,
+	}
+}
+
+func (u PathUpdate) internal() watch.PathUpdate {
+	return watch.PathUpdate{Path: u.Path, Remove: u.Remove}
+}
+
+type clockAdapter struct{ clock Clock }
+
+func (a clockAdapter) Now() time.Time                         { return a.clock.Now() }
+func (a clockAdapter) Since(t time.Time) time.Duration        { return a.clock.Since(t) }
+func (a clockAdapter) NewTicker(d time.Duration) clock.Ticker { return a.clock.NewTicker(d) }
+func (a clockAdapter) NewTimer(d time.Duration) clock.Timer   { return a.clock.NewTimer(d) }
+
+func internalClock(c Clock) clock.Clock {
+	switch c := c.(type) {
+	case nil:
+		return nil
+	case *FakeClock:
+		return c.fake
+	default:
+		return clockAdapter{clock: c}
+	}
+}
+
+func internalFS(filesystem FileSystem) fsys.FS {
+	switch filesystem := filesystem.(type) {
+	case nil:
+		return nil
+	case *MemoryFS:
+		return filesystem.memory
+	default:
+		return filesystem
+	}
+}
+
+type resolverAdapter struct{ resolver Resolver }
+
+func (a resolverAdapter) Resolve(path string, recursive bool) ([]string, error) {
+	return a.resolver.Resolve(path, recursive)
+}
+
+func (a resolverAdapter) ResolveRoot(root config.Root) ([]string, error) {
+	if resolver, ok := a.resolver.(RootResolver); ok {
+		return resolver.ResolveRoot(rootFrom(root))
+	}
+	return a.resolver.Resolve(root.Path, root.Recursive)
+}
+
+func internalResolver(resolver Resolver) input.Resolver {
+	switch resolver := resolver.(type) {
+	case nil:
+		return nil
+	case FileResolver:
+		return resolver.internal()
+	default:
+		return resolverAdapter{resolver: resolver}
+	}
+}
+
+type runnerAdapter struct{ runner CommandRunner }
+
+func (a runnerAdapter) Run(command, file string) runner.Result {
+	return a.runner.Run(command, file).internal()
+}
+
+func internalRunner(commandRunner CommandRunner) watch.CommandRunner {
+	switch commandRunner := commandRunner.(type) {
+	case nil:
+		return nil
+	case ShellRunner:
+		return runner.Shell{}
+	default:
+		return runnerAdapter{runner: commandRunner}
+	}
+}
+
+type reporterAdapter struct{ reporter OutputReporter }
+
+func (a reporterAdapter) Report(result runner.Result, opts config.Options) {
+	a.reporter.Report(resultFrom(result), optionsFrom(opts))
+}
+
+func internalReporter(reporter OutputReporter) watch.OutputReporter {
+	switch reporter := reporter.(type) {
+	case nil:
+		return nil
+	case ConsoleReporter:
+		return output.ConsoleReporter{Writer: reporter.Writer}
+	default:
+		return reporterAdapter{reporter: reporter}
+	}
+}
+
+type loggerAdapter struct{ logger ChangeLogger }
+
+func (a loggerAdapter) Write(entry string, result runner.Result) error {
+	return a.logger.Write(entry, resultFrom(result))
+}
+
+type gitAdapter struct{ monitor GitMonitor }
+
+func (a gitAdapter) State() gitinfo.State { return a.monitor.State().internal() }
+func (a gitAdapter) HeadPath() string     { return a.monitor.HeadPath() }
+
+func (a gitAdapter) Poll() (gitinfo.State, bool, error) {
+	previous, changed, err := a.monitor.Poll()
+	return previous.internal(), changed, err
+}
+
+type Logger struct{ logger *logging.Logger }
+
+func NewDebugLogger(writer io.Writer) *Logger {
+	return &Logger{logger: logging.New(writer, logging.LevelDebug)}
+}
+
+func (l *Logger) internal() *logging.Logger {
+	if l == nil {
+		return nil
+	}
+	return l.logger
+}
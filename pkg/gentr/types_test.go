@@ -0,0 +1,76 @@
+package gentr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tiendu/gentr/internal/config"
+)
+
+type rootRecorder struct{ roots []Root }
+
+func (r *rootRecorder) Resolve(path string, _ bool) ([]string, error) {
+	return []string{path}, nil
+}
+
+func (r *rootRecorder) ResolveRoot(root Root) ([]string, error) {
+	r.roots = append(r.roots, root)
+	return []string{root.Path + "/a.go"}, nil
+}
+
+func TestOptionsRoundTripThroughInternalOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Roots = []Root{{Path: "src", Recursive: true, Exclude: []string{"*_test.go"}, PollInterval: 250 * time.Millisecond}}
+	opts.Pipeline = []Step{{Name: "build", Command: "make"}, {Name: "test", Command: "make test", Needs: []string{"build"}}}
+	opts.Attributes = []string{config.AttributeMtime}
+	opts.Wait = true
+
+	if got := optionsFrom(opts.internal()); !reflect.DeepEqual(got, opts) {
+		t.Fatalf("round trip changed options:\n got %+v\nwant %+v", got, opts)
+	}
+	if internal := opts.internal(); internal.PollInterval != time.Second || len(internal.Pipeline[1].Needs) != 1 {
+		t.Fatalf("unexpected internal options: %+v", internal)
+	}
+}
+
+func TestResultsRoundTripThroughInternalResults(t *testing.T) {
+	result := Result{RawOutput: "ok", ExitCode: 2, Command: "make", File: "a.go b.go", Files: []string{"a.go", "b.go"}, Duration: time.Second, Step: "build", Pipeline: true}
+	if got := resultFrom(result.internal()); !reflect.DeepEqual(got, result) {
+		t.Fatalf("round trip changed result:\n got %+v\nwant %+v", got, result)
+	}
+	if state := (GitState{Branch: "main", Commit: "abc"}); state.String() != state.internal().String() {
+		t.Fatalf("unexpected git state string: %s", state)
+	}
+}
+
+func TestAdaptersCallPublicImplementations(t *testing.T) {
+	recorder := &rootRecorder{}
+	files, err := internalResolver(recorder).(interface {
+		ResolveRoot(config.Root) ([]string, error)
+	}).ResolveRoot(config.Root{Path: "src", Recursive: true})
+	if err != nil || len(files) != 1 || files[0] != "src/a.go" {
+		t.Fatalf("unexpected files %v, err %v", files, err)
+	}
+	if len(recorder.roots) != 1 || !recorder.roots[0].Recursive {
+		t.Fatalf("unexpected roots: %+v", recorder.roots)
+	}
+
+	fake := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	if internalClock(fake) != fake.fake {
+		t.Fatal("expected the fake clock to be unwrapped")
+	}
+	adapted := internalClock(clockOnly{fake})
+	timer := adapted.NewTimer(time.Second)
+	fake.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("expected the adapted timer to fire")
+	}
+	if internalClock(nil) != nil || internalFS(nil) != nil || internalResolver(nil) != nil {
+		t.Fatal("expected nil dependencies to stay nil")
+	}
+}
+
+type clockOnly struct{ Clock }